@@ -0,0 +1,48 @@
+package main
+
+import (
+	"go.codycody31.dev/support/alias"
+	"go.codycody31.dev/support/completion"
+
+	"github.com/urfave/cli/v2"
+)
+
+var AliasCommand = &cli.Command{
+	Name:  "alias",
+	Usage: "Manage command aliases",
+	Description: `Aliases are shortcuts for full command lines, stored in the aliases
+   section of config.yaml. $1, $2... are replaced by the arguments given to
+   the alias and $@ by all of them; without placeholders the arguments are
+   appended. For example:
+
+     support alias add prdel "caprover delete --regex '^pr-\$1' --dry-run"
+     support prdel 42`,
+	Subcommands: []*cli.Command{
+		{
+			Name:      "add",
+			Usage:     "Add or replace an alias",
+			ArgsUsage: "<name> <command line>",
+			Action:    alias.AddAlias,
+		},
+		{
+			Name:      "rm",
+			Usage:     "Remove an alias",
+			ArgsUsage: "<name>",
+			Action:    alias.RemoveAlias,
+		},
+		{
+			Name:   "list",
+			Usage:  "List aliases",
+			Action: alias.ListAliases,
+		},
+	},
+}
+
+func init() {
+	completion.Register("alias rm", func(command []string, flag, prefix string) []string {
+		if flag != "" {
+			return nil
+		}
+		return alias.Names()
+	})
+}
@@ -0,0 +1,201 @@
+// Package alias expands the user defined command aliases of config.yaml
+// before the command line is dispatched.
+package alias
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/shlex"
+)
+
+// placeholder matches the positional parameters of an alias: $1 to $9,
+// ${10} and above, and $@ for all the arguments.
+var placeholder = regexp.MustCompile(`\$(\d|\{\d+\}|@)`)
+
+// Expand replaces the alias named by the first command of args, the full
+// command line including the program name, with its definition. Arguments
+// following the alias fill its positional parameters, or are appended when
+// the alias has none. Built in and plugin commands always take precedence.
+func Expand(app *cli.App, args []string) ([]string, error) {
+	i := commandIndex(app, args)
+	if i < 0 {
+		return args, nil
+	}
+
+	name := args[i]
+	definition, exists := config.GetConfig().Aliases[name]
+	if !exists || app.Command(name) != nil {
+		return args, nil
+	}
+
+	expanded, err := substitute(name, definition, args[i+1:])
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append([]string(nil), args[:i]...), expanded...), nil
+}
+
+// substitute returns the words of definition with its positional parameters
+// replaced by params.
+func substitute(name, definition string, params []string) ([]string, error) {
+	words, err := shlex.Split(definition)
+	if err != nil {
+		return nil, errs.Config("invalid alias %s: %v", name, err)
+	}
+
+	if !placeholder.MatchString(definition) {
+		return append(words, params...), nil
+	}
+
+	var expanded []string
+	for _, word := range words {
+		if word == "$@" {
+			expanded = append(expanded, params...)
+			continue
+		}
+
+		var missing error
+		word = placeholder.ReplaceAllStringFunc(word, func(match string) string {
+			ref := strings.Trim(match[1:], "{}")
+			if ref == "@" {
+				return strings.Join(params, " ")
+			}
+			n, _ := strconv.Atoi(ref)
+			if n < 1 || n > len(params) {
+				missing = errs.Usage("alias %s expects at least %d argument(s)", name, n)
+				return ""
+			}
+			return params[n-1]
+		})
+		if missing != nil {
+			return nil, missing
+		}
+		expanded = append(expanded, word)
+	}
+	return expanded, nil
+}
+
+// commandIndex returns the index in args of the first command, skipping the
+// program name and the global flags, or -1 if there is none.
+func commandIndex(app *cli.App, args []string) int {
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return -1
+		}
+		if !strings.HasPrefix(arg, "-") {
+			return i
+		}
+		if !strings.Contains(arg, "=") && flagTakesValue(app.Flags, strings.TrimLeft(arg, "-")) {
+			i++
+		}
+	}
+	return -1
+}
+
+func flagTakesValue(flags []cli.Flag, name string) bool {
+	for _, flag := range flags {
+		for _, flagName := range flag.Names() {
+			if flagName != name {
+				continue
+			}
+			if f, ok := flag.(interface{ TakesValue() bool }); ok {
+				return f.TakesValue()
+			}
+			return false
+		}
+	}
+	return false
+}
+
+// Names returns the names of the aliases, sorted.
+func Names() []string {
+	var names []string
+	for name := range config.GetConfig().Aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func AddAlias(c *cli.Context) error {
+	name := c.Args().First()
+	words := c.Args().Tail()
+	if name == "" || len(words) == 0 {
+		return errs.Usage("usage: %s alias add <name> <command line>", c.App.Name)
+	}
+	if strings.HasPrefix(name, "-") || strings.ContainsAny(name, " \t'\"$") {
+		return errs.Usage("invalid alias name %q", name)
+	}
+	if c.App.Command(name) != nil {
+		return errs.Usage("alias %s collides with the %s command", name, name)
+	}
+
+	// A single argument is taken as a full command line, several as words
+	// already split by the shell
+	definition := words[0]
+	if len(words) > 1 {
+		quoted := make([]string, len(words))
+		for i, word := range words {
+			quoted[i] = shlex.Quote(word)
+		}
+		definition = strings.Join(quoted, " ")
+	}
+	if _, err := shlex.Split(definition); err != nil {
+		return err
+	}
+
+	configData := config.GetConfig()
+	if configData.Aliases == nil {
+		configData.Aliases = make(map[string]string)
+	}
+	configData.Aliases[name] = definition
+	config.SaveConfig()
+
+	fmt.Printf("Alias %s added: %s\n", name, definition)
+	return nil
+}
+
+func RemoveAlias(c *cli.Context) error {
+	name := c.Args().First()
+	if name == "" {
+		return errs.Usage("an alias name is required")
+	}
+
+	configData := config.GetConfig()
+	if _, exists := configData.Aliases[name]; !exists {
+		return errs.NotFound("alias %s does not exist", name)
+	}
+
+	delete(configData.Aliases, name)
+	config.SaveConfig()
+
+	fmt.Printf("Alias %s removed\n", name)
+	return nil
+}
+
+func ListAliases(c *cli.Context) error {
+	names := Names()
+	if len(names) == 0 {
+		fmt.Println("No aliases defined")
+		return nil
+	}
+
+	fmt.Println("Aliases:")
+	for _, name := range names {
+		note := ""
+		if c.App.Command(name) != nil {
+			note = " (shadowed by the command of the same name)"
+		}
+		fmt.Printf("  %s = %s%s\n", name, config.GetConfig().Aliases[name], note)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"go.codycody31.dev/support/audit"
+
+	"github.com/urfave/cli/v2"
+)
+
+var AuditCommand = &cli.Command{
+	Name:  "audit",
+	Usage: "Query the log of executed commands",
+	Subcommands: []*cli.Command{
+		{
+			Name:   "list",
+			Usage:  "List the most recent commands",
+			Action: audit.ListEntries,
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:    "limit",
+					Aliases: []string{"n"},
+					Usage:   "Number of records to show",
+					Value:   20,
+				},
+			},
+		},
+		{
+			Name:      "show",
+			Usage:     "Show a single record",
+			ArgsUsage: "<id>",
+			Action:    audit.ShowEntry,
+		},
+		{
+			Name:      "search",
+			Usage:     "Search the log",
+			ArgsUsage: "[text]",
+			Action:    audit.SearchEntries,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "command",
+					Aliases: []string{"c"},
+					Usage:   "Only commands starting with this path, e.g. \"caprover delete\"",
+				},
+				&cli.StringFlag{
+					Name:    "user",
+					Aliases: []string{"u"},
+					Usage:   "Only commands run by this user",
+				},
+				&cli.DurationFlag{
+					Name:    "since",
+					Aliases: []string{"s"},
+					Usage:   "Only commands run within this duration, e.g. 24h",
+				},
+				&cli.BoolFlag{
+					Name:  "failed",
+					Usage: "Only failed commands",
+				},
+				&cli.IntFlag{
+					Name:    "limit",
+					Aliases: []string{"n"},
+					Usage:   "Maximum number of records to show",
+				},
+			},
+		},
+	},
+}
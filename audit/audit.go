@@ -0,0 +1,349 @@
+// Package audit appends a JSONL record for every command run by support to
+// ~/.support/audit.log and provides the commands to query it.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/host"
+	"go.codycody31.dev/support/plugins"
+)
+
+// Entry is a single audit record.
+type Entry struct {
+	ID            string            `json:"id"`
+	Time          time.Time         `json:"time"`
+	User          string            `json:"user"`
+	Host          string            `json:"host"`
+	Profile       string            `json:"profile"`
+	Command       string            `json:"command"`
+	Args          []string          `json:"args,omitempty"`
+	Flags         map[string]string `json:"flags,omitempty"`
+	DurationMS    int64             `json:"duration_ms"`
+	ExitCode      int               `json:"exit_code"`
+	Error         string            `json:"error,omitempty"`
+	Plugin        string            `json:"plugin,omitempty"`
+	PluginVersion string            `json:"plugin_version,omitempty"`
+}
+
+const redacted = "REDACTED"
+
+// secretPatterns match secrets in any value: the password of a URL,
+// name=value pairs and authorization headers, and tokens recognizable by
+// their format. The matches are replaced by their replacement.
+var secretPatterns = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+@`), "${1}" + redacted + "@"},
+	{regexp.MustCompile(`(?i)((?:` + strings.Join(host.SecretWords, "|") + `)[a-z0-9_-]*["']?\s*[=:]\s*["']?(?:bearer\s+|basic\s+)?)[^\s"'&,;]+`), "${1}" + redacted},
+	{regexp.MustCompile(`(?i)\b((?:bearer|basic)\s+)[a-z0-9._~+/=-]{8,}`), "${1}" + redacted},
+	{regexp.MustCompile(`\b(?:tk_[A-Za-z0-9]{8,}|gh[pousr]_[A-Za-z0-9]{20,}|github_pat_[A-Za-z0-9_]{20,}|glpat-[A-Za-z0-9_-]{20,}|xox[abposr]-[A-Za-z0-9-]{10,}|sk-[A-Za-z0-9_-]{20,}|AKIA[0-9A-Z]{16}|eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+)`), redacted},
+	{regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*`), redacted},
+}
+
+const (
+	defaultMaxSizeMB = 10
+	defaultMaxFiles  = 5
+)
+
+func logFilePath() string {
+	return filepath.Join(config.SupportDir(), "audit.log")
+}
+
+// Wrap wraps the action of every runnable command so that each run is
+// recorded. Hidden commands, such as the one called by shell completion,
+// are not recorded.
+func Wrap(commands []*cli.Command) {
+	for _, cmd := range commands {
+		if cmd.Hidden {
+			continue
+		}
+		if cmd.Action != nil && len(cmd.Subcommands) == 0 {
+			cmd.Action = wrapAction(cmd.Action)
+		}
+		Wrap(cmd.Subcommands)
+	}
+}
+
+func wrapAction(action cli.ActionFunc) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		start := time.Now()
+		err := action(c)
+		Record(NewEntry(c, start, err))
+		return err
+	}
+}
+
+// NewEntry builds the record of a command that started at start and
+// returned err.
+func NewEntry(c *cli.Context, start time.Time, err error) *Entry {
+	entry := &Entry{
+		ID:         fmt.Sprintf("%x", start.UnixNano()),
+		Time:       start.UTC(),
+		Profile:    config.Profile(),
+		Command:    strings.Join(host.CommandPath(c), " "),
+		Args:       RedactArgs(c.Args().Slice()),
+		Flags:      flagValues(c),
+		DurationMS: time.Since(start).Milliseconds(),
+		ExitCode:   errs.ExitCode(err),
+	}
+
+	if u, err := user.Current(); err == nil {
+		entry.User = u.Username
+	}
+	entry.Host, _ = os.Hostname()
+
+	if err != nil {
+		entry.Error = redactValue(err.Error())
+	}
+
+	if path := host.CommandPath(c); len(path) > 0 {
+		if p := plugins.ForCommand(path[0]); p != nil {
+			entry.Plugin = p.Name
+			entry.PluginVersion = p.Version
+		}
+	}
+
+	return entry
+}
+
+// flagValues returns the flags set on the command line for c and its parent
+// commands, with secrets redacted.
+func flagValues(c *cli.Context) map[string]string {
+	values := make(map[string]string)
+	for _, ctx := range c.Lineage() {
+		if ctx.Command == nil {
+			continue
+		}
+		for _, flag := range ctx.Command.Flags {
+			name := flag.Names()[0]
+			if _, exists := values[name]; exists || !ctx.IsSet(name) {
+				continue
+			}
+			// Flags with secret names are never written to the log
+			if host.IsSecret(name) {
+				values[name] = redacted
+				continue
+			}
+			values[name] = redactValue(fmt.Sprint(ctx.Value(name)))
+		}
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
+// redactValue replaces the parts of s looking like secrets.
+func redactValue(s string) string {
+	for _, secret := range secretPatterns {
+		s = secret.pattern.ReplaceAllString(s, secret.replacement)
+	}
+	return s
+}
+
+func redactValues(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	redactedValues := make([]string, len(values))
+	for i, value := range values {
+		redactedValues[i] = redactValue(value)
+	}
+	return redactedValues
+}
+
+// RedactArgs returns the words of a command line with the values of the
+// flags with secret names, as in --password value or --token=value, and
+// anything else looking like a secret redacted.
+func RedactArgs(words []string) []string {
+	redactedWords := redactValues(words)
+	for i, word := range words {
+		if !strings.HasPrefix(word, "-") {
+			continue
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(word, "-"), "=")
+		if !host.IsSecret(name) {
+			continue
+		}
+		if hasValue {
+			redactedWords[i] = word[:strings.Index(word, "=")+1] + redacted
+		} else if i+1 < len(words) && !strings.HasPrefix(words[i+1], "-") {
+			redactedWords[i+1] = redacted
+		}
+	}
+	return redactedWords
+}
+
+// Record appends entry to the audit log and forwards it to syslog if
+// enabled. Failures are reported on stderr but never fail the command.
+func Record(entry *Entry) {
+	auditConfig := config.GetConfig().Audit
+	if auditConfig.Disabled {
+		return
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error encoding audit record:", err)
+		return
+	}
+
+	if err := appendLine(line, auditConfig); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing audit log:", err)
+	}
+
+	if auditConfig.Syslog {
+		if err := forwardToSyslog(auditConfig.SyslogAddress, line); err != nil {
+			fmt.Fprintln(os.Stderr, "Error forwarding audit record to syslog:", err)
+		}
+	}
+}
+
+// appendLine appends line to the log and rotates it when it is over the
+// maximum size, with the lock of the log held so that concurrent processes
+// do not rotate it twice.
+func appendLine(line []byte, auditConfig config.AuditConfig) error {
+	path := logFilePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	lock, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(line, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := rotate(auditConfig); err != nil {
+		return fmt.Errorf("failed to rotate: %v", err)
+	}
+	return nil
+}
+
+// rotatedPath returns the path of the nth rotated log, audit.log.1 being
+// the most recent.
+func rotatedPath(n int) string {
+	return logFilePath() + "." + strconv.Itoa(n)
+}
+
+func maxFiles(auditConfig config.AuditConfig) int {
+	if auditConfig.MaxFiles > 0 {
+		return auditConfig.MaxFiles
+	}
+	return defaultMaxFiles
+}
+
+// rotate renames the log to audit.log.1, shifting the older logs and
+// removing the oldest, once it is over the maximum size.
+func rotate(auditConfig config.AuditConfig) error {
+	maxSize := int64(auditConfig.MaxSizeMB) << 20
+	if maxSize <= 0 {
+		maxSize = defaultMaxSizeMB << 20
+	}
+	path := logFilePath()
+	info, err := os.Stat(path)
+	if err != nil || info.Size() <= maxSize {
+		return nil
+	}
+
+	keep := maxFiles(auditConfig)
+	if err := os.Remove(rotatedPath(keep)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for n := keep - 1; n >= 1; n-- {
+		if err := os.Rename(rotatedPath(n), rotatedPath(n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(path, rotatedPath(1))
+}
+
+func forwardToSyslog(address string, line []byte) error {
+	var writer *syslog.Writer
+	var err error
+	if address == "" {
+		writer, err = syslog.New(syslog.LOG_INFO|syslog.LOG_USER, "support")
+	} else {
+		writer, err = syslog.Dial("unixgram", address, syslog.LOG_INFO|syslog.LOG_USER, "support")
+	}
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+
+	return writer.Info(string(line))
+}
+
+// readEntries returns all records of the audit log and of the rotated logs,
+// oldest first.
+func readEntries() ([]*Entry, error) {
+	var entries []*Entry
+	for n := maxFiles(config.GetConfig().Audit); n >= 0; n-- {
+		path := logFilePath()
+		if n > 0 {
+			path = rotatedPath(n)
+		}
+		fileEntries, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fileEntries...)
+	}
+	return entries, nil
+}
+
+func readFile(path string) ([]*Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []*Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		entry := &Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			// Skip lines damaged by a crash or a concurrent write
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/errs"
+)
+
+func ListEntries(c *cli.Context) error {
+	entries, err := readEntries()
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %v", err)
+	}
+
+	printEntries(last(entries, c.Int("limit")))
+	return nil
+}
+
+func ShowEntry(c *cli.Context) error {
+	id := c.Args().First()
+	if id == "" {
+		return errs.Usage("an audit record ID is required")
+	}
+
+	entries, err := readEntries()
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %v", err)
+	}
+
+	for _, entry := range entries {
+		if entry.ID == id {
+			out, err := json.MarshalIndent(entry, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+			return nil
+		}
+	}
+
+	return errs.NotFound("audit record %s not found", id)
+}
+
+func SearchEntries(c *cli.Context) error {
+	term := strings.ToLower(strings.Join(c.Args().Slice(), " "))
+	command := c.String("command")
+	userName := c.String("user")
+	failed := c.Bool("failed")
+
+	var since time.Time
+	if c.IsSet("since") {
+		since = time.Now().Add(-c.Duration("since"))
+	}
+
+	entries, err := readEntries()
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %v", err)
+	}
+
+	var matches []*Entry
+	for _, entry := range entries {
+		if command != "" && !strings.HasPrefix(entry.Command, command) {
+			continue
+		}
+		if userName != "" && entry.User != userName {
+			continue
+		}
+		if failed && entry.ExitCode == 0 {
+			continue
+		}
+		if !since.IsZero() && entry.Time.Before(since) {
+			continue
+		}
+		if term != "" {
+			line, _ := json.Marshal(entry)
+			if !strings.Contains(strings.ToLower(string(line)), term) {
+				continue
+			}
+		}
+		matches = append(matches, entry)
+	}
+
+	printEntries(last(matches, c.Int("limit")))
+	return nil
+}
+
+func last(entries []*Entry, n int) []*Entry {
+	if n > 0 && len(entries) > n {
+		return entries[len(entries)-n:]
+	}
+	return entries
+}
+
+func printEntries(entries []*Entry) {
+	if len(entries) == 0 {
+		fmt.Println("No audit records found")
+		return
+	}
+
+	for _, entry := range entries {
+		fmt.Printf("%s  %s  %s@%s  %-10s  %-30s  exit=%d  %dms\n",
+			entry.ID,
+			entry.Time.Local().Format(time.RFC3339),
+			entry.User,
+			entry.Host,
+			entry.Profile,
+			entry.Command,
+			entry.ExitCode,
+			entry.DurationMS,
+		)
+	}
+}
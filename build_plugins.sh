@@ -12,7 +12,7 @@ mkdir -p "$OUTPUT_DIR"
 for dir in "$PLUGIN_DIR"/*; do
     if [ -d "$dir" ]; then
         plugin_name=$(basename "$dir")
-        go build -o "$OUTPUT_DIR/${plugin_name}.so" -buildmode=plugin "$dir/${plugin_name}.go"
+        go build -o "$OUTPUT_DIR/${plugin_name}.so" -buildmode=plugin "$dir"
         echo "Built ${plugin_name}.so"
     fi
 done
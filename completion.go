@@ -0,0 +1,38 @@
+package main
+
+import (
+	"go.codycody31.dev/support/completion"
+
+	"github.com/urfave/cli/v2"
+)
+
+var CompletionCommand = &cli.Command{
+	Name:      "completion",
+	Usage:     "Generate a shell completion script",
+	ArgsUsage: "bash|zsh|fish",
+	Description: `Prints a completion script covering the commands of support and of the
+   enabled plugins. For example:
+
+     support completion bash > /etc/bash_completion.d/support
+     support completion zsh > "${fpath[1]}/_support"
+     support completion fish > ~/.config/fish/completions/support.fish`,
+	Action: completion.PrintScript,
+}
+
+// CompleteCommand is called by the completion scripts with the words typed
+// so far and prints the candidates for the last one.
+var CompleteCommand = &cli.Command{
+	Name:            "__complete",
+	Hidden:          true,
+	SkipFlagParsing: true,
+	Action:          completion.PrintCandidates,
+}
+
+func init() {
+	completion.Register("completion", func(command []string, flag, prefix string) []string {
+		if flag != "" {
+			return nil
+		}
+		return completion.Shells()
+	})
+}
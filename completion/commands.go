@@ -0,0 +1,31 @@
+package completion
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/errs"
+)
+
+func PrintScript(c *cli.Context) error {
+	shell := c.Args().First()
+	if shell == "" {
+		return errs.Usage("a shell is required, one of %s", strings.Join(Shells(), ", "))
+	}
+
+	script, err := Script(shell, c.App.Name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(script)
+	return nil
+}
+
+func PrintCandidates(c *cli.Context) error {
+	for _, candidate := range Complete(c.App, c.Args().Slice()) {
+		fmt.Println(candidate)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+// Package completion computes command line completions from the command tree
+// of the app, including the commands added by plugins, and renders the shell
+// scripts calling back into `support __complete`.
+package completion
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/plugins"
+)
+
+// Hook returns the candidate values for a flag of command, or for its
+// positional arguments when flag is empty. command is the path of the
+// command, e.g. ["caprover", "delete"]. Candidates not starting with prefix
+// are filtered out by the caller.
+//
+// Plugins provide a hook by exporting a function named Complete with this
+// signature.
+type Hook func(command []string, flag, prefix string) []string
+
+var hooks = make(map[string]Hook)
+
+// Register sets the hook of a built in command, given by its path such as
+// "plugins enable".
+func Register(command string, hook Hook) {
+	hooks[command] = hook
+}
+
+// Complete returns the candidates for the last of words, the arguments
+// typed after the name of the app. The last word is the one being
+// completed and is empty when the cursor follows a space.
+func Complete(app *cli.App, words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+
+	var path []string
+	commands := app.Commands
+	flags := app.Flags
+	valueOf := ""
+
+	for i := 0; i < len(words)-1; i++ {
+		word := words[i]
+		valueOf = ""
+
+		if word == "--" {
+			continue
+		}
+		if strings.HasPrefix(word, "-") {
+			name := strings.TrimLeft(word, "-")
+			if strings.Contains(name, "=") {
+				continue
+			}
+			if flag := findFlag(flags, name); flag != nil && takesValue(flag) {
+				valueOf = flag.Names()[0]
+			}
+			continue
+		}
+
+		if i > 0 && isValueFlag(flags, words[i-1]) {
+			// The value of the previous flag
+			continue
+		}
+
+		if cmd := findCommand(commands, word); cmd != nil {
+			path = append(path, cmd.Name)
+			commands = cmd.Subcommands
+			flags = cmd.Flags
+		}
+	}
+
+	var candidates []string
+	switch {
+	case valueOf != "":
+		candidates = hookCandidates(path, valueOf, current)
+	case strings.HasPrefix(current, "-"):
+		for _, flag := range flags {
+			for _, name := range flag.Names() {
+				if len(name) > 1 {
+					candidates = append(candidates, "--"+name)
+				}
+			}
+		}
+	default:
+		for _, cmd := range commands {
+			if !cmd.Hidden {
+				candidates = append(candidates, cmd.Name)
+			}
+		}
+		if len(path) == 0 {
+			for name := range config.GetConfig().Aliases {
+				candidates = append(candidates, name)
+			}
+		}
+		candidates = append(candidates, hookCandidates(path, "", current)...)
+	}
+
+	return filter(candidates, current)
+}
+
+func hookCandidates(path []string, flag, prefix string) []string {
+	if len(path) == 0 {
+		return nil
+	}
+
+	if hook, exists := hooks[strings.Join(path, " ")]; exists {
+		return hook(path, flag, prefix)
+	}
+
+	if p := plugins.ForCommand(path[0]); p != nil && p.Complete != nil {
+		return p.Complete(path, flag, prefix)
+	}
+	return nil
+}
+
+// filter returns the sorted unique candidates starting with prefix.
+func filter(candidates []string, prefix string) []string {
+	seen := make(map[string]bool)
+	var matches []string
+	for _, candidate := range candidates {
+		if seen[candidate] || !strings.HasPrefix(candidate, prefix) {
+			continue
+		}
+		seen[candidate] = true
+		matches = append(matches, candidate)
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+func findCommand(commands []*cli.Command, name string) *cli.Command {
+	for _, cmd := range commands {
+		if cmd.HasName(name) {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func findFlag(flags []cli.Flag, name string) cli.Flag {
+	for _, flag := range flags {
+		for _, flagName := range flag.Names() {
+			if flagName == name {
+				return flag
+			}
+		}
+	}
+	return nil
+}
+
+func takesValue(flag cli.Flag) bool {
+	if f, ok := flag.(interface{ TakesValue() bool }); ok {
+		return f.TakesValue()
+	}
+	return false
+}
+
+func isValueFlag(flags []cli.Flag, word string) bool {
+	if !strings.HasPrefix(word, "-") || strings.Contains(word, "=") {
+		return false
+	}
+	flag := findFlag(flags, strings.TrimLeft(word, "-"))
+	return flag != nil && takesValue(flag)
+}
@@ -0,0 +1,58 @@
+package completion
+
+import (
+	"bytes"
+	"text/template"
+
+	"go.codycody31.dev/support/errs"
+)
+
+var scripts = map[string]string{
+	"bash": `# bash completion for {{.}}
+_{{.}}_complete() {
+    local IFS=$'\n'
+    COMPREPLY=($({{.}} __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _{{.}}_complete {{.}}
+`,
+	"zsh": `#compdef {{.}}
+# zsh completion for {{.}}
+_{{.}}() {
+    local -a candidates
+    candidates=("${(@f)$({{.}} __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    compadd -a candidates
+}
+compdef _{{.}} {{.}}
+`,
+	"fish": `# fish completion for {{.}}
+function __{{.}}_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    {{.}} __complete $tokens[2..-1] 2>/dev/null
+end
+complete -c {{.}} -f -a '(__{{.}}_complete)'
+`,
+}
+
+// Shells returns the shells a script can be generated for.
+func Shells() []string {
+	return []string{"bash", "zsh", "fish"}
+}
+
+// Script returns the completion script of shell for the binary named name.
+func Script(shell, name string) (string, error) {
+	text, exists := scripts[shell]
+	if !exists {
+		return "", errs.Usage("unsupported shell %q, expected one of bash, zsh or fish", shell)
+	}
+
+	tmpl, err := template.New(shell).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var script bytes.Buffer
+	if err := tmpl.Execute(&script, name); err != nil {
+		return "", err
+	}
+	return script.String(), nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
@@ -13,16 +14,180 @@ type Config struct {
 	// PluginsDir     string                            `yaml:"plugins_dir"`
 	PluginDirs     []string                          `yaml:"plugin_dirs"`
 	PluginSettings map[string]map[string]interface{} `yaml:"plugin_settings"`
+	// Profiles holds plugin settings that override PluginSettings when the
+	// profile is selected with --profile.
+	Profiles map[string]map[string]map[string]interface{} `yaml:"profiles,omitempty"`
+	Audit    AuditConfig                                  `yaml:"audit,omitempty"`
+	// Aliases maps a name to the command line it stands for.
+	Aliases   map[string]string `yaml:"aliases,omitempty"`
+	Scheduler SchedulerConfig   `yaml:"scheduler,omitempty"`
+	Serve     ServeConfig       `yaml:"serve,omitempty"`
+	Webhooks  WebhooksConfig    `yaml:"webhooks,omitempty"`
+	// HTTP configures the HTTP client of plugins. A plugin can override it
+	// with an "http" entry of the same shape in its settings.
+	HTTP   HTTPConfig   `yaml:"http,omitempty"`
+	Update UpdateConfig `yaml:"update,omitempty"`
+	Stats  StatsConfig  `yaml:"stats,omitempty"`
+	Notify NotifyConfig `yaml:"notify,omitempty"`
 }
 
+type NotifyConfig struct {
+	// Channels are the destinations of `support notify send --to <name>`,
+	// each sending to one or more providers.
+	Channels map[string][]NotifyProvider `yaml:"channels,omitempty"`
+}
+
+// NotifyProvider is a provider of a notification channel.
+type NotifyProvider struct {
+	// Type is the kind of provider, e.g. ntfy, gotify, matrix, slack, smtp
+	// or discord.
+	Type string `yaml:"type"`
+	// Name identifies the provider in the output, its type when empty.
+	Name string `yaml:"name,omitempty"`
+	// Settings are the other keys, specific to the type.
+	Settings map[string]interface{} `yaml:",inline"`
+}
+
+type StatsConfig struct {
+	// Disabled stops recording usage statistics.
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Retention is how long records are kept, 90 days when unset.
+	Retention time.Duration `yaml:"retention,omitempty"`
+}
+
+type UpdateConfig struct {
+	// URL is where releases are downloaded from: a GitHub repository
+	// (https://github.com/<owner>/<repo>), the URL of a mirror or a local
+	// directory. The GitHub repository of support when empty.
+	URL string `yaml:"url,omitempty"`
+	// Channel is "stable" (the default) or "beta", which includes
+	// prereleases.
+	Channel string `yaml:"channel,omitempty"`
+	// PublicKey is the base64 encoded ed25519 key verifying the signature
+	// of the checksums of releases, replacing the key built in.
+	PublicKey string `yaml:"public_key,omitempty"`
+}
+
+// HTTPConfig configures the HTTP client shared by plugins.
+type HTTPConfig struct {
+	// Timeout limits a request, retries included. There is no limit when
+	// unset, beyond --timeout.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Retries is the number of times a failed request is retried, 2 when
+	// unset. Use -1 to disable retries.
+	Retries int `yaml:"retries,omitempty"`
+	// RetryWait is the delay before the first retry, doubled for each
+	// following one, 500ms when unset.
+	RetryWait time.Duration `yaml:"retry_wait,omitempty"`
+	// Proxy is the URL of the HTTP(S) proxy, the HTTP_PROXY and HTTPS_PROXY
+	// environment variables are used when empty.
+	Proxy string `yaml:"proxy,omitempty"`
+	// CAFile is a PEM bundle of certificate authorities trusted in addition
+	// to the system ones.
+	CAFile string `yaml:"ca_file,omitempty"`
+	// CertFile and KeyFile are the PEM client certificate and key.
+	CertFile string `yaml:"cert_file,omitempty"`
+	KeyFile  string `yaml:"key_file,omitempty"`
+	// InsecureSkipVerify disables the verification of server certificates.
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify,omitempty"`
+}
+
+type ServeConfig struct {
+	// Token is the bearer token required by `support serve`.
+	Token string `yaml:"token,omitempty"`
+}
+
+type AuditConfig struct {
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Syslog forwards every audit record to the local syslog daemon.
+	Syslog bool `yaml:"syslog,omitempty"`
+	// SyslogAddress is the path of the syslog unix socket, the system
+	// default is used when empty.
+	SyslogAddress string `yaml:"syslog_address,omitempty"`
+	// MaxSizeMB is the size in megabytes past which the log is rotated, 10
+	// when unset.
+	MaxSizeMB int `yaml:"max_size_mb,omitempty"`
+	// MaxFiles is the number of rotated logs kept, 5 when unset.
+	MaxFiles int `yaml:"max_files,omitempty"`
+}
+
+type SchedulerConfig struct {
+	// Socket is the path of the unix socket of the scheduler daemon,
+	// ~/.support/scheduler.sock when empty.
+	Socket string         `yaml:"socket,omitempty"`
+	Jobs   []ScheduledJob `yaml:"jobs,omitempty"`
+}
+
+// ScheduledJob runs a routine or a single command on a schedule.
+type ScheduledJob struct {
+	Name string `yaml:"name"`
+	// Schedule is a cron expression, a macro such as @daily, or
+	// "@every <duration>".
+	Schedule string `yaml:"schedule"`
+	Routine  string `yaml:"routine,omitempty"`
+	// Command is a command line of support, e.g. "caprover list".
+	Command string `yaml:"command,omitempty"`
+	// Jitter delays each run by a random duration up to this value.
+	Jitter time.Duration `yaml:"jitter,omitempty"`
+	// Timeout stops runs lasting longer than this value.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// CatchUp is what to do with the runs missed while the scheduler was
+	// stopped: "skip" them (the default), run "once", or run "all".
+	CatchUp  string `yaml:"catch_up,omitempty"`
+	Disabled bool   `yaml:"disabled,omitempty"`
+}
+
+type WebhooksConfig struct {
+	// Listen is the address of `support webhook serve`, 127.0.0.1:9090
+	// when empty.
+	Listen string `yaml:"listen,omitempty"`
+	// Concurrency is the number of executions running at once, 1 when
+	// unset.
+	Concurrency int `yaml:"concurrency,omitempty"`
+	// QueueSize is the number of executions waiting before deliveries are
+	// refused, 100 when unset.
+	QueueSize int       `yaml:"queue_size,omitempty"`
+	Hooks     []Webhook `yaml:"hooks,omitempty"`
+}
+
+// Webhook maps a URL path to a routine or a command.
+type Webhook struct {
+	Path string `yaml:"path"`
+	// Secret verifies the HMAC signature of deliveries, they are not
+	// verified when empty.
+	Secret string `yaml:"secret,omitempty"`
+	// Signature is the signature scheme: "github" (the default) or
+	// "gitea".
+	Signature string `yaml:"signature,omitempty"`
+	Routine   string `yaml:"routine,omitempty"`
+	// Command is a command line of support, e.g. "ntfy send".
+	Command string `yaml:"command,omitempty"`
+	// Flags are added to the command, Vars are passed to the routine.
+	// Values starting with $ are JSONPath expressions into the payload,
+	// e.g. $.repository.name.
+	Flags map[string]string `yaml:"flags,omitempty"`
+	Vars  map[string]string `yaml:"vars,omitempty"`
+}
+
+// DefaultProfile is the profile whose settings live directly in
+// PluginSettings.
+const DefaultProfile = "default"
+
 var configFilePath string
 var config Config
+var profile = DefaultProfile
 
 func init() {
 	configFilePath = getConfigFilePath()
 	loadConfig()
 }
 
+// SupportDir returns the directory holding the configuration and state of
+// support, usually ~/.support.
+func SupportDir() string {
+	return getSupportDir()
+}
+
 func getSupportDir() string {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -37,7 +202,10 @@ func getConfigFilePath() string {
 	return filepath.Join(getSupportDir(), "config.yaml")
 }
 
+// loadConfig reads the config file. A missing file is only created by the
+// first change saved, so that reading the config never writes it.
 func loadConfig() {
+	config = Config{}
 	file, err := os.Open(configFilePath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -46,7 +214,6 @@ func loadConfig() {
 				PluginSettings: make(map[string]map[string]interface{}),
 				PluginDirs:     []string{filepath.Join(getSupportDir(), "plugins")},
 			}
-			saveConfig()
 			return
 		}
 		fmt.Println("Error loading config:", err)
@@ -62,12 +229,24 @@ func loadConfig() {
 }
 
 func saveConfig() {
-	file, err := os.Create(configFilePath)
+	err := os.MkdirAll(filepath.Dir(configFilePath), 0755)
+	if err != nil {
+		fmt.Println("Error saving config:", err)
+		return
+	}
+
+	// The config holds tokens and passwords, so only the user may read it,
+	// including when it was created readable by others
+	file, err := os.OpenFile(configFilePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
 	if err != nil {
 		fmt.Println("Error saving config:", err)
 		return
 	}
 	defer file.Close()
+	if err := file.Chmod(0600); err != nil {
+		fmt.Println("Error saving config:", err)
+		return
+	}
 
 	encoder := yaml.NewEncoder(file)
 	err = encoder.Encode(&config)
@@ -84,21 +263,67 @@ func SaveConfig() {
 	saveConfig()
 }
 
-func UpdatePluginSetting(pluginName, key string, value interface{}) error {
-	if config.PluginSettings == nil {
-		config.PluginSettings = make(map[string]map[string]interface{})
+// Reload reads the config again, from the support directory of the current
+// home directory. Tests use it after pointing HOME at a temporary directory.
+func Reload() {
+	configFilePath = getConfigFilePath()
+	loadConfig()
+}
+
+// SetProfile selects the profile used by GetPluginSetting and
+// UpdatePluginSetting.
+func SetProfile(name string) {
+	if name == "" {
+		name = DefaultProfile
 	}
+	profile = name
+}
+
+// Profile returns the selected profile.
+func Profile() string {
+	return profile
+}
 
-	if _, exists := config.PluginSettings[pluginName]; !exists {
-		config.PluginSettings[pluginName] = make(map[string]interface{})
+// pluginSettings returns the settings map written by UpdatePluginSetting for
+// the selected profile.
+func pluginSettings() map[string]map[string]interface{} {
+	if profile == DefaultProfile {
+		if config.PluginSettings == nil {
+			config.PluginSettings = make(map[string]map[string]interface{})
+		}
+		return config.PluginSettings
+	}
+
+	if config.Profiles == nil {
+		config.Profiles = make(map[string]map[string]map[string]interface{})
+	}
+	if _, exists := config.Profiles[profile]; !exists {
+		config.Profiles[profile] = make(map[string]map[string]interface{})
 	}
+	return config.Profiles[profile]
+}
+
+func UpdatePluginSetting(pluginName, key string, value interface{}) error {
+	settings := pluginSettings()
 
-	config.PluginSettings[pluginName][key] = value
+	if _, exists := settings[pluginName]; !exists {
+		settings[pluginName] = make(map[string]interface{})
+	}
+
+	settings[pluginName][key] = value
 	SaveConfig()
 	return nil
 }
 
 func GetPluginSetting(pluginName, key string) (interface{}, bool) {
+	if profile != DefaultProfile {
+		if pluginSettings, exists := config.Profiles[profile][pluginName]; exists {
+			if value, exists := pluginSettings[key]; exists {
+				return value, true
+			}
+		}
+	}
+
 	if config.PluginSettings == nil {
 		return nil, false
 	}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"go.codycody31.dev/support/docs"
+
+	"github.com/urfave/cli/v2"
+)
+
+var outFlag = &cli.StringFlag{
+	Name:    "out",
+	Aliases: []string{"o"},
+	Usage:   "Directory to write the pages to",
+	Value:   "docs",
+}
+
+var DocsCommand = &cli.Command{
+	Name:  "docs",
+	Usage: "Generate the reference of the commands, including those of the loaded plugins",
+	Subcommands: []*cli.Command{
+		{
+			Name:   "man",
+			Usage:  "Generate man pages",
+			Action: docs.GenerateMan,
+			Flags:  []cli.Flag{outFlag},
+		},
+		{
+			Name:   "markdown",
+			Usage:  "Generate Markdown pages",
+			Action: docs.GenerateMarkdown,
+			Flags:  []cli.Flag{outFlag},
+		},
+	},
+}
@@ -0,0 +1,160 @@
+// Package docs implements `support docs`, generating the reference of the
+// commands, including those of the loaded plugins, as man pages or Markdown.
+package docs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cpuguy83/go-md2man/v2/md2man"
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/plugins"
+)
+
+// manSection is the section of the man pages, user commands.
+const manSection = 1
+
+// page is a generated document.
+type page struct {
+	// name is the name of the page without extension, e.g. support-ntfy.
+	name     string
+	markdown string
+}
+
+// GenerateMan writes support.1 and a support-<plugin>.1 page for each
+// loaded plugin to the --out directory.
+func GenerateMan(c *cli.Context) error {
+	return generate(c, true)
+}
+
+// GenerateMarkdown writes support.md and a support-<plugin>.md page for each
+// loaded plugin to the --out directory.
+func GenerateMarkdown(c *cli.Context) error {
+	return generate(c, false)
+}
+
+func generate(c *cli.Context, man bool) error {
+	if c.Args().Present() {
+		return errs.Usage("unexpected arguments: %s", strings.Join(c.Args().Slice(), " "))
+	}
+
+	pages, err := pages(c.App, man)
+	if err != nil {
+		return err
+	}
+
+	out := c.String("out")
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", out, err)
+	}
+
+	for _, p := range pages {
+		path := filepath.Join(out, p.name+".md")
+		content := []byte(p.markdown)
+		if man {
+			path = filepath.Join(out, fmt.Sprintf("%s.%d", p.name, manSection))
+			content = md2man.Render(content)
+		}
+
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", path, err)
+		}
+		fmt.Println("Wrote", path)
+	}
+	return nil
+}
+
+// pages returns the page of the app followed by those of the plugins, in
+// Markdown. Pages meant for md2man start with a title line.
+func pages(app *cli.App, man bool) ([]*page, error) {
+	reference, err := app.ToMarkdown()
+	if err != nil {
+		return nil, fmt.Errorf("failed to render the reference: %v", err)
+	}
+
+	var pluginPages []*page
+	var seeAlso []string
+	for _, p := range plugins.Loaded() {
+		markdown, err := pluginMarkdown(p, app.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		name := app.Name + "-" + p.Name
+		if man {
+			markdown = title(name) + markdown
+			seeAlso = append(seeAlso, fmt.Sprintf("**%s**(%d)", name, manSection))
+		} else {
+			line := fmt.Sprintf("- [%s](%s.md)", p.Name, name)
+			if p.Manifest.Description != "" {
+				line += " - " + p.Manifest.Description
+			}
+			seeAlso = append(seeAlso, line)
+		}
+		pluginPages = append(pluginPages, &page{name: name, markdown: markdown})
+	}
+
+	if len(seeAlso) > 0 {
+		if man {
+			reference += "\n# SEE ALSO\n\n" + strings.Join(seeAlso, ", ") + "\n"
+		} else {
+			reference += "\n# PLUGINS\n\n" + strings.Join(seeAlso, "\n") + "\n"
+		}
+	}
+	if man {
+		reference = title(app.Name) + reference
+	}
+
+	return append([]*page{{name: app.Name, markdown: reference}}, pluginPages...), nil
+}
+
+func title(name string) string {
+	return fmt.Sprintf("%% %s %d\n\n", name, manSection)
+}
+
+// pluginMarkdown renders the commands of the plugin p followed by its
+// manifest and settings.
+func pluginMarkdown(p *plugins.Plugin, appName string) (string, error) {
+	pluginApp := &cli.App{
+		Name:      appName + "-" + p.Name,
+		Usage:     p.Manifest.Description,
+		UsageText: appName + " [GLOBAL OPTIONS] command [COMMAND OPTIONS] [ARGUMENTS...]",
+		Commands:  p.Commands,
+	}
+	markdown, err := pluginApp.ToMarkdown()
+	if err != nil {
+		return "", fmt.Errorf("failed to render the reference of %s: %v", p.Name, err)
+	}
+
+	var b strings.Builder
+	b.WriteString(markdown)
+
+	b.WriteString("\n# PLUGIN\n\n")
+	fmt.Fprintf(&b, "**Name**: %s\n\n", p.Name)
+	if p.Version != "" {
+		fmt.Fprintf(&b, "**Version**: %s\n\n", p.Version)
+	}
+	fmt.Fprintf(&b, "Enable it with `%s plugins enable %s`.\n", appName, p.Name)
+
+	b.WriteString("\n# SETTINGS\n\n")
+	fmt.Fprintf(&b, "Read from `plugin_settings.%s` of `~/.support/config.yaml`, overridden by `profiles.<profile>.%s` when a profile is selected.\n\n", p.Name, p.Name)
+	if len(p.Manifest.Settings) == 0 {
+		b.WriteString("The plugin does not describe its settings.\n")
+	} else {
+		b.WriteString("| Key | Type | Default | Description |\n")
+		b.WriteString("| --- | ---- | ------- | ----------- |\n")
+		for _, setting := range p.Manifest.Settings {
+			description := setting.Description
+			if setting.Secret {
+				description += " (secret)"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", setting.Key, setting.Type, setting.Default, description)
+		}
+	}
+	b.WriteString("\nThe `http` key configures the HTTP client of the plugin, with the keys of the `http` section of `config.yaml`.\n")
+
+	return b.String(), nil
+}
@@ -0,0 +1,215 @@
+// Package errs defines the error categories shared by the core and plugins
+// and the process exit code each category maps to.
+//
+// Exit codes are part of the public interface of the support binary so that
+// wrappers (cron jobs, CI scripts) can decide whether a failure is worth
+// retrying:
+//
+//	0  success
+//	1  unknown or uncategorised error
+//	2  usage error (bad flags or arguments)
+//	3  configuration error (missing or invalid settings)
+//	4  authentication error (rejected credentials or token)
+//	5  network error (server unreachable, request failed) - retryable
+//	6  not found (app, container, topic, file...)
+//	7  plugin load error
+//	8  remote API error (the server refused the request)
+//	9  unavailable (the server failed or is overloaded: 5xx, 429) - retryable
+//	124 timeout (the --timeout of the command expired)
+//	130 canceled (interrupted by SIGINT or SIGTERM)
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Kind is the category of an error.
+type Kind int
+
+const (
+	KindUnknown Kind = iota
+	KindUsage
+	KindConfig
+	KindAuth
+	KindNetwork
+	KindNotFound
+	KindPluginLoad
+	KindRemoteAPI
+	KindUnavailable
+	KindTimeout
+	KindCanceled
+)
+
+var kindNames = map[Kind]string{
+	KindUnknown:     "unknown",
+	KindUsage:       "usage",
+	KindConfig:      "config",
+	KindAuth:        "auth",
+	KindNetwork:     "network",
+	KindNotFound:    "not-found",
+	KindPluginLoad:  "plugin-load",
+	KindRemoteAPI:   "remote-api",
+	KindUnavailable: "unavailable",
+	KindTimeout:     "timeout",
+	KindCanceled:    "canceled",
+}
+
+var exitCodes = map[Kind]int{
+	KindUnknown:     1,
+	KindUsage:       2,
+	KindConfig:      3,
+	KindAuth:        4,
+	KindNetwork:     5,
+	KindNotFound:    6,
+	KindPluginLoad:  7,
+	KindRemoteAPI:   8,
+	KindUnavailable: 9,
+	KindTimeout:     124,
+	KindCanceled:    130,
+}
+
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return kindNames[KindUnknown]
+}
+
+// ExitCode returns the process exit code for the kind.
+func (k Kind) ExitCode() int {
+	if code, ok := exitCodes[k]; ok {
+		return code
+	}
+	return exitCodes[KindUnknown]
+}
+
+// KindFromExitCode returns the kind whose exit code is code, for example to
+// categorise the failure of a child process. It returns KindUnknown for
+// codes not listed above.
+func KindFromExitCode(code int) Kind {
+	for kind, kindCode := range exitCodes {
+		if kindCode == code {
+			return kind
+		}
+	}
+	return KindUnknown
+}
+
+// Error is an error tagged with a Kind.
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New creates an error of the given kind. The format string is passed to
+// fmt.Errorf, so %w can be used to keep the underlying error.
+func New(kind Kind, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// Wrap tags err with kind. It returns nil if err is nil.
+func Wrap(kind Kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+func Usage(format string, args ...interface{}) error {
+	return New(KindUsage, format, args...)
+}
+
+func Config(format string, args ...interface{}) error {
+	return New(KindConfig, format, args...)
+}
+
+func Auth(format string, args ...interface{}) error {
+	return New(KindAuth, format, args...)
+}
+
+func Network(format string, args ...interface{}) error {
+	return New(KindNetwork, format, args...)
+}
+
+func NotFound(format string, args ...interface{}) error {
+	return New(KindNotFound, format, args...)
+}
+
+func PluginLoad(format string, args ...interface{}) error {
+	return New(KindPluginLoad, format, args...)
+}
+
+func RemoteAPI(format string, args ...interface{}) error {
+	return New(KindRemoteAPI, format, args...)
+}
+
+// RemoteStatus returns the error of a response with the HTTP status: a
+// KindUnavailable error when the server failed on its side or asked to slow
+// down (5xx, 429), which may succeed later, else a KindRemoteAPI error.
+func RemoteStatus(status int, format string, args ...interface{}) error {
+	if status >= 500 || status == 429 {
+		return New(KindUnavailable, format, args...)
+	}
+	return New(KindRemoteAPI, format, args...)
+}
+
+// KindOf returns the kind of the outermost *Error in err's chain, or
+// KindUnknown if there is none. Errors caused by the expiry or the
+// cancellation of a context are KindTimeout and KindCanceled whatever they
+// were tagged with, since a request failing because the command was
+// interrupted is not a network error.
+func KindOf(err error) Kind {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return KindTimeout
+	}
+	if errors.Is(err, context.Canceled) {
+		return KindCanceled
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindUnknown
+}
+
+// ExitCode returns the process exit code for err. Errors created with
+// cli.Exit keep the code they were given.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	if kind := KindOf(err); kind != KindUnknown {
+		return kind.ExitCode()
+	}
+
+	var exitErr cli.ExitCoder
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	return KindUnknown.ExitCode()
+}
+
+// Retryable reports whether the failure is transient and the command may
+// succeed if run again.
+func Retryable(err error) bool {
+	switch KindOf(err) {
+	case KindNetwork, KindUnavailable:
+		return true
+	}
+	return false
+}
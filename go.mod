@@ -2,10 +2,15 @@ module go.codycody31.dev/support
 
 go 1.18
 
-require github.com/urfave/cli/v2 v2.27.2
+require (
+	github.com/urfave/cli/v2 v2.27.2
+	golang.org/x/term v0.27.0
+)
+
+require golang.org/x/sys v0.28.0 // indirect
 
 require (
-	github.com/cpuguy83/go-md2man/v2 v2.0.4 // indirect
+	github.com/cpuguy83/go-md2man/v2 v2.0.4
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/xrash/smetrics v0.0.0-20240312152122-5f08fbb34913 // indirect
 	gopkg.in/yaml.v2 v2.4.0
@@ -0,0 +1,115 @@
+// Package guard protects destructive commands: they honor the global
+// --dry-run flag, ask for confirmation before acting, and refuse to run
+// without --yes when nobody can answer.
+package guard
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/host"
+	"golang.org/x/term"
+)
+
+var (
+	destructive = make(map[*cli.Command]bool)
+	dryRun      bool
+	assumeYes   bool
+)
+
+// Destructive marks cmd as destructive and returns it, so that it can wrap
+// the definition of the command:
+//
+//	guard.Destructive(&cli.Command{Name: "delete", ...})
+//
+// The action of a destructive command should return early when DryRun is
+// true, and call Confirm with the targets before acting on them.
+func Destructive(cmd *cli.Command) *cli.Command {
+	destructive[cmd] = true
+	return cmd
+}
+
+// IsDestructive reports whether cmd was marked with Destructive.
+func IsDestructive(cmd *cli.Command) bool {
+	return destructive[cmd]
+}
+
+// Set records the global --dry-run and --yes flags.
+func Set(dryRunFlag, yesFlag bool) {
+	dryRun = dryRunFlag
+	assumeYes = yesFlag
+}
+
+// DryRun reports whether the command of c should only print what it would
+// do, because of the global --dry-run flag or a --dry-run flag of its own.
+func DryRun(c *cli.Context) bool {
+	return dryRun || c.Bool("dry-run")
+}
+
+// GlobalDryRun reports whether the global --dry-run flag was given.
+func GlobalDryRun() bool {
+	return dryRun
+}
+
+// AssumeYes reports whether --yes was given.
+func AssumeYes() bool {
+	return assumeYes
+}
+
+// Interactive reports whether the user can answer a confirmation prompt.
+func Interactive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// Wrap wraps the action of every destructive command, so that they are
+// refused upfront when they could not be confirmed.
+func Wrap(commands []*cli.Command) {
+	for _, cmd := range commands {
+		if destructive[cmd] && cmd.Action != nil {
+			cmd.Action = wrapAction(cmd.Action)
+		}
+		Wrap(cmd.Subcommands)
+	}
+}
+
+func wrapAction(action cli.ActionFunc) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		if !DryRun(c) && !assumeYes && !Interactive() {
+			return errs.Usage("%s is destructive and cannot be confirmed without a terminal, use --yes or --dry-run", strings.Join(host.CommandPath(c), " "))
+		}
+		return action(c)
+	}
+}
+
+// Confirm asks the user to confirm the action on targets, for example
+// Confirm(c, "delete", apps). It returns nil when there is no target or
+// --yes was given, and an error exiting with code 1 when the user declines,
+// so that declining is not mistaken for an interruption.
+func Confirm(c *cli.Context, action string, targets []string) error {
+	if len(targets) == 0 || assumeYes {
+		return nil
+	}
+	if !Interactive() {
+		return errs.Usage("refusing to %s %d target(s) without confirmation, use --yes", action, len(targets))
+	}
+
+	fmt.Fprintf(os.Stderr, "About to %s:\n", action)
+	for _, target := range targets {
+		fmt.Fprintf(os.Stderr, "  %s\n", target)
+	}
+	fmt.Fprint(os.Stderr, "Continue? [y/N] ")
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return errs.New(errs.KindUnknown, "%s not confirmed, nothing was done", action)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return errs.New(errs.KindCanceled, "%s canceled by the user", action)
+}
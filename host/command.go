@@ -0,0 +1,20 @@
+package host
+
+import "github.com/urfave/cli/v2"
+
+// CommandPath returns the names of the commands from the first subcommand
+// of the app down to the command of c, e.g. ["caprover", "list"].
+func CommandPath(c *cli.Context) []string {
+	var path []string
+	for _, ctx := range c.Lineage() {
+		if ctx.Command != nil {
+			path = append([]string{ctx.Command.Name}, path...)
+		}
+	}
+
+	// The first command is the app itself
+	if len(path) > 0 {
+		path = path[1:]
+	}
+	return path
+}
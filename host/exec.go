@@ -0,0 +1,69 @@
+package host
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// killDelay is how long a process has to exit after being interrupted
+// before it is killed.
+const killDelay = 10 * time.Second
+
+// Run runs cmd until it exits. When ctx is cancelled the process is sent
+// SIGINT, and killed if it is still running after a grace period, so that
+// it can stop cleanly. It returns ctx.Err() if the process was stopped.
+func Run(ctx context.Context, cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	cmd.Process.Signal(os.Interrupt)
+	select {
+	case err := <-done:
+		if err == nil {
+			return nil
+		}
+	case <-time.After(killDelay):
+		cmd.Process.Kill()
+		<-done
+	}
+	return ctx.Err()
+}
+
+// CombinedOutput runs the program name with args and returns its combined
+// standard output and standard error. See Run for the handling of ctx.
+func CombinedOutput(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var output bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := Run(ctx, cmd)
+	return output.Bytes(), err
+}
+
+// Output runs the program name with args and returns its standard output.
+// See Run for the handling of ctx.
+func Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var output bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = os.Stderr
+
+	err := Run(ctx, cmd)
+	return output.Bytes(), err
+}
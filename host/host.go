@@ -0,0 +1,4 @@
+// Package host provides the services the core offers to commands and
+// plugins: signal aware contexts, and helpers running processes and HTTP
+// requests that honor the context of the command.
+package host
@@ -0,0 +1,173 @@
+package host
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+	"gopkg.in/yaml.v2"
+)
+
+// Defaults of the HTTP client.
+const (
+	defaultRetries   = 2
+	defaultRetryWait = 500 * time.Millisecond
+)
+
+var (
+	httpClientsMu sync.Mutex
+	httpClients   = make(map[string]*http.Client)
+	// hiddenPaths are the plugins whose clients only trace the scheme and
+	// the host of the URLs.
+	hiddenPaths = make(map[string]bool)
+)
+
+// HideTracedPaths makes the HTTP client of the plugin trace only the scheme
+// and the host of the URLs requested, for APIs such as webhooks whose URLs
+// carry secrets in their path. It must be called before HTTPClient.
+func HideTracedPaths(plugin string) {
+	httpClientsMu.Lock()
+	defer httpClientsMu.Unlock()
+	hiddenPaths[plugin] = true
+}
+
+// HTTPClient returns the HTTP client of the plugin, configured by the http
+// section of config.yaml and the http entry of the plugin settings of the
+// selected profile. It retries failed requests, see config.HTTPConfig.
+//
+// Requests must be created with http.NewRequestWithContext and the context
+// of the command (c.Context) so that they are cancelled with it.
+func HTTPClient(plugin string) (*http.Client, error) {
+	settings, err := httpConfig(plugin)
+	if err != nil {
+		return nil, err
+	}
+
+	key := plugin + "\x00" + config.Profile()
+	httpClientsMu.Lock()
+	defer httpClientsMu.Unlock()
+	if client, ok := httpClients[key]; ok {
+		return client, nil
+	}
+
+	client, err := newHTTPClient(plugin, settings, hiddenPaths[plugin])
+	if err != nil {
+		return nil, err
+	}
+	httpClients[key] = client
+	return client, nil
+}
+
+// httpConfig returns the global HTTP settings overridden by those of the
+// plugin.
+func httpConfig(plugin string) (config.HTTPConfig, error) {
+	settings := config.GetConfig().HTTP
+
+	value, exists := config.GetPluginSetting(plugin, "http")
+	if !exists {
+		return settings, nil
+	}
+
+	// Settings are loaded as generic maps, decode them the way config.yaml
+	// is decoded
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return settings, errs.Config("invalid http settings of %s: %v", plugin, err)
+	}
+	var overrides config.HTTPConfig
+	if err := yaml.UnmarshalStrict(data, &overrides); err != nil {
+		return settings, errs.Config("invalid http settings of %s: %v", plugin, err)
+	}
+
+	if overrides.Timeout != 0 {
+		settings.Timeout = overrides.Timeout
+	}
+	if overrides.Retries != 0 {
+		settings.Retries = overrides.Retries
+	}
+	if overrides.RetryWait != 0 {
+		settings.RetryWait = overrides.RetryWait
+	}
+	if overrides.Proxy != "" {
+		settings.Proxy = overrides.Proxy
+	}
+	if overrides.CAFile != "" {
+		settings.CAFile = overrides.CAFile
+	}
+	if overrides.CertFile != "" {
+		settings.CertFile = overrides.CertFile
+		settings.KeyFile = overrides.KeyFile
+	}
+	if overrides.InsecureSkipVerify {
+		settings.InsecureSkipVerify = true
+	}
+	return settings, nil
+}
+
+func newHTTPClient(plugin string, settings config.HTTPConfig, hidePath bool) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if settings.Proxy != "" {
+		proxy, err := url.Parse(settings.Proxy)
+		if err != nil {
+			return nil, errs.Config("invalid http proxy %s: %v", settings.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	tlsConfig := &tls.Config{}
+	if settings.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(settings.CAFile)
+		if err != nil {
+			return nil, errs.Config("failed to read CA bundle: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errs.Config("no certificate found in CA bundle %s", settings.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if settings.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(settings.CertFile, settings.KeyFile)
+		if err != nil {
+			return nil, errs.Config("failed to load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	if settings.InsecureSkipVerify {
+		fmt.Fprintf(os.Stderr, "Warning: TLS certificate verification is disabled for %s\n", plugin)
+		tlsConfig.InsecureSkipVerify = true
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	retries := settings.Retries
+	switch {
+	case retries == 0:
+		retries = defaultRetries
+	case retries < 0:
+		retries = 0
+	}
+	retryWait := settings.RetryWait
+	if retryWait <= 0 {
+		retryWait = defaultRetryWait
+	}
+
+	return &http.Client{
+		Timeout: settings.Timeout,
+		Transport: &retryTransport{
+			next:    &traceTransport{next: transport, hidePath: hidePath},
+			retries: retries,
+			wait:    retryWait,
+		},
+	}, nil
+}
@@ -0,0 +1,14 @@
+package host
+
+import "os"
+
+// StdinPiped reports whether stdin is a pipe or a regular file, which can be
+// read to the end without waiting for anyone. A terminal, or /dev/null and
+// the sockets services and cron jobs run with, are not.
+func StdinPiped() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeNamedPipe != 0 || info.Mode().IsRegular()
+}
@@ -0,0 +1,131 @@
+package host
+
+import (
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxRetryWait caps the delay before a retry, including the delays asked
+// by servers with Retry-After.
+const maxRetryWait = 30 * time.Second
+
+// retryTransport retries requests failing with a network error or a 429 or
+// 5xx response, with an exponential backoff.
+//
+// Only idempotent requests are retried after a network error or a 5xx
+// response, since the server may have processed them. A 429 response means
+// the request was refused, so every request is retried.
+type retryTransport struct {
+	next    http.RoundTripper
+	retries int
+	// wait is the delay before the first retry.
+	wait time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			// The body was consumed by the previous attempt
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= t.retries || !retryable(req, resp, err) {
+			return resp, err
+		}
+
+		wait := t.backoff(attempt, resp)
+		if resp != nil {
+			// Reuse the connection
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable reports whether the request may be sent again after resp or
+// err.
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// The body cannot be sent again
+		return false
+	}
+
+	if err != nil {
+		return idempotent(req)
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return true
+	case resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented:
+		return idempotent(req)
+	}
+	return false
+}
+
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// backoff returns the delay before the retry following attempt: the delay
+// asked by the server with Retry-After, or the exponential backoff with
+// jitter.
+func (t *retryTransport) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if wait, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			if wait > maxRetryWait {
+				wait = maxRetryWait
+			}
+			return wait
+		}
+	}
+
+	wait := t.wait << uint(attempt)
+	if wait > maxRetryWait || wait <= 0 {
+		wait = maxRetryWait
+	}
+	// Up to 25% of jitter, so that clients do not retry in lockstep
+	return wait - time.Duration(rand.Int63n(int64(wait)/4+1))
+}
+
+// retryAfter parses a Retry-After header, either a number of seconds or an
+// HTTP date.
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
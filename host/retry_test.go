@@ -0,0 +1,98 @@
+package host
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryBodies(t *testing.T) {
+	var attempts int
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &retryTransport{next: http.DefaultTransport, retries: 3, wait: time.Millisecond}}
+
+	tests := []struct {
+		name string
+		req  func() *http.Request
+		body string
+	}{
+		{
+			name: "no body",
+			req: func() *http.Request {
+				req, _ := http.NewRequest("GET", server.URL, nil)
+				return req
+			},
+		},
+		{
+			name: "NoBody without GetBody",
+			req: func() *http.Request {
+				req, _ := http.NewRequest("DELETE", server.URL, nil)
+				req.Body = http.NoBody
+				req.GetBody = nil
+				return req
+			},
+		},
+		{
+			name: "body with GetBody",
+			req: func() *http.Request {
+				req, _ := http.NewRequest("PUT", server.URL, strings.NewReader("hello"))
+				return req
+			},
+			body: "hello",
+		},
+	}
+
+	for _, test := range tests {
+		attempts, bodies = 0, nil
+		resp, err := client.Do(test.req())
+		if err != nil {
+			t.Errorf("%s: request failed: %v", test.name, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || attempts != 3 {
+			t.Errorf("%s: status %d after %d attempts, expected 200 after 3", test.name, resp.StatusCode, attempts)
+		}
+		for i, body := range bodies {
+			if body != test.body {
+				t.Errorf("%s: attempt %d sent %q, expected %q", test.name, i+1, body, test.body)
+			}
+		}
+	}
+}
+
+func TestRetryUnreplayableBody(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &retryTransport{next: http.DefaultTransport, retries: 3, wait: time.Millisecond}}
+	req, _ := http.NewRequest("PUT", server.URL, io.NopCloser(strings.NewReader("stream")))
+	req.GetBody = nil
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if attempts != 1 {
+		t.Errorf("a body that cannot be sent again was sent %d times", attempts)
+	}
+}
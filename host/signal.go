@@ -0,0 +1,77 @@
+package host
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// interruptible is a context cancelled by SIGINT or SIGTERM.
+type interruptible struct {
+	cancel      context.CancelFunc
+	interrupted bool
+}
+
+var (
+	signalsOnce    sync.Once
+	interruptMu    sync.Mutex
+	interruptibles []*interruptible
+)
+
+// Interruptible returns a context cancelled on the first SIGINT or SIGTERM
+// received until release is called. A second signal exits the process.
+//
+// Contexts can be nested, such as the commands run by `support shell`: a
+// signal only cancels the innermost context.
+func Interruptible(parent context.Context) (ctx context.Context, release func()) {
+	signalsOnce.Do(handleSignals)
+
+	ctx, cancel := context.WithCancel(parent)
+	i := &interruptible{cancel: cancel}
+
+	interruptMu.Lock()
+	interruptibles = append(interruptibles, i)
+	interruptMu.Unlock()
+
+	return ctx, func() {
+		interruptMu.Lock()
+		for n, other := range interruptibles {
+			if other == i {
+				interruptibles = append(interruptibles[:n], interruptibles[n+1:]...)
+				break
+			}
+		}
+		interruptMu.Unlock()
+		cancel()
+	}
+}
+
+func handleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		for range signals {
+			interruptMu.Lock()
+			if len(interruptibles) == 0 {
+				interruptMu.Unlock()
+				os.Exit(130)
+			}
+
+			innermost := interruptibles[len(interruptibles)-1]
+			if innermost.interrupted {
+				interruptMu.Unlock()
+				fmt.Fprintln(os.Stderr, "Interrupted again, exiting")
+				os.Exit(130)
+			}
+			innermost.interrupted = true
+			innermost.cancel()
+			interruptMu.Unlock()
+
+			fmt.Fprintln(os.Stderr, "Interrupted, stopping (press Ctrl-C again to exit immediately)")
+		}
+	}()
+}
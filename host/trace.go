@@ -0,0 +1,112 @@
+package host
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const redacted = "REDACTED"
+
+// SecretWords are the words marking a flag, a header or a query parameter
+// as secret, in traces and in the audit log.
+var SecretWords = []string{"password", "passwd", "token", "secret", "key", "auth", "credential", "cookie", "signature"}
+
+var traceHTTP int32
+
+// SetTraceHTTP enables logging a summary of every HTTP request and response
+// to stderr, with secrets redacted. It is set by --trace-http.
+func SetTraceHTTP(enabled bool) {
+	value := int32(0)
+	if enabled {
+		value = 1
+	}
+	atomic.StoreInt32(&traceHTTP, value)
+}
+
+// traceTransport logs requests when tracing is enabled.
+type traceTransport struct {
+	next http.RoundTripper
+	// hidePath only logs the scheme and the host of the URLs.
+	hidePath bool
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if atomic.LoadInt32(&traceHTTP) == 0 {
+		return t.next.RoundTrip(req)
+	}
+
+	var trace strings.Builder
+	target := redactURL(req.URL)
+	if t.hidePath {
+		target = req.URL.Scheme + "://" + req.URL.Host + "/" + redacted
+	}
+	fmt.Fprintf(&trace, "> %s %s\n", req.Method, target)
+	writeHeaders(&trace, ">", req.Header)
+	fmt.Fprint(os.Stderr, trace.String())
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	duration := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "< error after %s: %v\n", duration, err)
+		return resp, err
+	}
+
+	trace.Reset()
+	fmt.Fprintf(&trace, "< %s %s (%s, %d bytes)\n", resp.Proto, resp.Status, duration, resp.ContentLength)
+	writeHeaders(&trace, "<", resp.Header)
+	fmt.Fprint(os.Stderr, trace.String())
+	return resp, nil
+}
+
+func writeHeaders(trace *strings.Builder, prefix string, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := strings.Join(header[name], ", ")
+		if IsSecret(name) {
+			value = redacted
+		}
+		fmt.Fprintf(trace, "%s %s: %s\n", prefix, name, value)
+	}
+}
+
+// redactURL returns u with its password and secret query parameters
+// redacted.
+func redactURL(u *url.URL) string {
+	redactedURL := *u
+	if _, hasPassword := u.User.Password(); hasPassword {
+		redactedURL.User = url.UserPassword(u.User.Username(), redacted)
+	}
+
+	query := u.Query()
+	for name := range query {
+		if IsSecret(name) {
+			query.Set(name, redacted)
+		}
+	}
+	redactedURL.RawQuery = query.Encode()
+	return redactedURL.String()
+}
+
+// IsSecret reports whether name, of a flag, a header or a query parameter,
+// contains one of SecretWords.
+func IsSecret(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range SecretWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
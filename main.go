@@ -1,26 +1,160 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"os"
 
+	"go.codycody31.dev/support/alias"
+	"go.codycody31.dev/support/audit"
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/guard"
+	"go.codycody31.dev/support/host"
 	"go.codycody31.dev/support/plugins"
+	"go.codycody31.dev/support/stats"
 
 	"github.com/urfave/cli/v2"
 )
 
+// version is set at build time with -ldflags "-X main.version=<version>".
+var version = "dev"
+
 func main() {
+	// timeouts holds the cancel functions of the contexts of --timeout by
+	// run of the app, commands of `support shell` running it again.
+	timeouts := make(map[*cli.Context]context.CancelFunc)
+
 	app := &cli.App{
-		Name:  "support",
-		Usage: "System Utilities and Plugin-based Operations, Routines, and Tasks",
+		Name:    "support",
+		Usage:   "System Utilities and Plugin-based Operations, Routines, and Tasks",
+		Version: version,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "profile",
+				Usage:   "Configuration profile to use",
+				EnvVars: []string{"SUPPORT_PROFILE"},
+				Value:   config.DefaultProfile,
+			},
+			&cli.DurationFlag{
+				Name:    "timeout",
+				Usage:   "Stop the command if it runs longer than this duration, e.g. 30s",
+				EnvVars: []string{"SUPPORT_TIMEOUT"},
+			},
+			&cli.BoolFlag{
+				Name:    "trace-http",
+				Usage:   "Log the HTTP requests and responses of plugins to stderr, secrets redacted",
+				EnvVars: []string{"SUPPORT_TRACE_HTTP"},
+			},
+			&cli.BoolFlag{
+				Name:    "dry-run",
+				Usage:   "Print what destructive commands would do instead of doing it",
+				EnvVars: []string{"SUPPORT_DRY_RUN"},
+			},
+			&cli.BoolFlag{
+				Name:    "yes",
+				Aliases: []string{"y"},
+				Usage:   "Do not ask for confirmation before destructive commands",
+				EnvVars: []string{"SUPPORT_YES"},
+			},
+		},
+		Before: func(c *cli.Context) error {
+			config.SetProfile(c.String("profile"))
+			host.SetTraceHTTP(c.Bool("trace-http"))
+			guard.Set(c.Bool("dry-run"), c.Bool("yes"))
+			if timeout := c.Duration("timeout"); timeout > 0 {
+				var cancel context.CancelFunc
+				c.Context, cancel = context.WithTimeout(c.Context, timeout)
+				timeouts[c] = cancel
+			}
+			return nil
+		},
+		// After also runs when Before did not, or failed
+		After: func(c *cli.Context) error {
+			if cancel, exists := timeouts[c]; exists {
+				cancel()
+				delete(timeouts, c)
+			}
+			return nil
+		},
 		Commands: []*cli.Command{
 			PluginsCommand,
+			AuditCommand,
+			ShellCommand,
+			CompletionCommand,
+			CompleteCommand,
+			AliasCommand,
+			RoutineCommand,
+			SchedulerCommand,
+			ServeCommand,
+			WebhookCommand,
+			SelfUpdateCommand,
+			DocsCommand,
+			StatsCommand,
+			NotifyCommand,
 		},
+		// Errors are reported once, by main, with the exit code of their
+		// category instead of urfave/cli's default handling.
+		ExitErrHandler: func(c *cli.Context, err error) {},
+		OnUsageError:   usageError,
+		Action:         unknownCommand,
 	}
 
 	plugins.LoadPlugins(app)
+	setUsageErrorHandlers(app.Commands)
+	guard.Wrap(app.Commands)
+	audit.Wrap(app.Commands)
+	stats.Wrap(app.Commands)
+
+	// The context of every command is cancelled on SIGINT and SIGTERM, so
+	// that requests and processes it started are stopped.
+	ctx, release := host.Interruptible(context.Background())
 
-	err := app.Run(os.Args)
+	args, err := alias.Expand(app, os.Args)
+	if err == nil {
+		err = app.RunContext(ctx, args)
+	}
+	release()
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(errs.ExitCode(err))
+	}
+}
+
+func usageError(c *cli.Context, err error, isSubcommand bool) error {
+	return errs.Wrap(errs.KindUsage, err)
+}
+
+// unknownCommand shows the help of a command group, or reports a usage error
+// when it was given a subcommand that does not exist. A command missing
+// because its plugin failed to load reports the plugin load error instead.
+func unknownCommand(c *cli.Context) error {
+	// The app runs as a root command named after it
+	root := c.Command == nil || c.Command.Name == "" || c.Command.Name == c.App.Name
+	if c.Args().Present() {
+		if root {
+			if failure := plugins.FailureFor(c.Args().First()); failure != nil {
+				return failure.Err
+			}
+		}
+		return errs.Usage("unknown command %q", c.Args().First())
+	}
+	if root {
+		return cli.ShowAppHelp(c)
+	}
+	return cli.ShowSubcommandHelp(c)
+}
+
+// setUsageErrorHandlers makes flag parsing errors and unknown subcommands of
+// every command, including those added by plugins, report as usage errors.
+func setUsageErrorHandlers(commands []*cli.Command) {
+	for _, cmd := range commands {
+		if cmd.OnUsageError == nil {
+			cmd.OnUsageError = usageError
+		}
+		if cmd.Action == nil && len(cmd.Subcommands) > 0 {
+			cmd.Action = unknownCommand
+		}
+		setUsageErrorHandlers(cmd.Subcommands)
 	}
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"go.codycody31.dev/support/completion"
+	"go.codycody31.dev/support/notify"
+
+	"github.com/urfave/cli/v2"
+)
+
+var NotifyCommand = &cli.Command{
+	Name:  "notify",
+	Usage: "Send notifications to the channels of config.yaml",
+	Description: `Channels are read from the notify section of config.yaml, each sends to
+   one or more providers:
+
+     notify:
+       channels:
+         oncall:
+           - type: ntfy            # provided by the ntfy plugin
+             topic: ops
+             server: home          # a server of ntfy servers add
+           - type: slack
+             url: https://hooks.slack.com/services/...
+           - type: smtp
+             host: smtp.example.com
+             username: alerts@example.com
+             password: s3cret
+             from: alerts@example.com
+             to: [oncall@example.com]
+
+   Provider types: ntfy, gotify, matrix, slack, smtp and discord.`,
+	Subcommands: []*cli.Command{
+		{
+			Name:   "send",
+			Usage:  "Send a notification to channels",
+			Action: notify.SendNotification,
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:  "to",
+					Usage: "Channels to notify, comma separated or repeated",
+				},
+				&cli.StringFlag{
+					Name:    "message",
+					Aliases: []string{"m"},
+					Usage:   "Notification message, read from stdin when it is piped",
+				},
+				&cli.StringFlag{
+					Name:  "title",
+					Usage: "Notification title",
+				},
+				&cli.StringFlag{
+					Name:    "priority",
+					Aliases: []string{"p"},
+					Usage:   "Priority, 1-5 or min, low, default, high, urgent",
+				},
+				&cli.StringSliceFlag{
+					Name:  "tags",
+					Usage: "Tags, comma separated",
+				},
+				&cli.StringSliceFlag{
+					Name:  "attach",
+					Usage: "Files to attach, repeated, skipped by the providers not supporting them",
+				},
+			},
+		},
+		{
+			Name:   "channels",
+			Usage:  "List the channels and their providers",
+			Action: notify.ListChannels,
+		},
+	},
+}
+
+func init() {
+	completion.Register("notify send", func(command []string, flag, prefix string) []string {
+		if flag != "to" {
+			return nil
+		}
+		return notify.ChannelNames()
+	})
+}
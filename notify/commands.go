@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/host"
+)
+
+func SendNotification(c *cli.Context) error {
+	channels := c.StringSlice("to")
+	if len(channels) == 0 {
+		return errs.Usage("--to is required, see `support notify channels`")
+	}
+
+	n := &Notification{
+		Title:       c.String("title"),
+		Body:        c.String("message"),
+		Tags:        c.StringSlice("tags"),
+		Attachments: c.StringSlice("attach"),
+	}
+	if c.IsSet("priority") {
+		priority, err := ParsePriority(c.String("priority"))
+		if err != nil {
+			return errs.Usage("%v", err)
+		}
+		n.Priority = priority
+	}
+	for _, channel := range channels {
+		if _, err := Channel(channel); err != nil {
+			return err
+		}
+	}
+
+	// Piped output is the message, read once the flags are known to be valid
+	if !c.IsSet("message") && host.StdinPiped() {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read the message from stdin: %v", err)
+		}
+		n.Body = strings.TrimRight(string(data), "\n")
+	}
+	if n.Body == "" && n.Title == "" && len(n.Attachments) == 0 {
+		return errs.Usage("a message is required, with --message or on stdin")
+	}
+
+	results, err := Send(c.Context, channels, n)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	var firstErr error
+	for _, result := range results {
+		name := result.Channel + "/" + result.Target.Name
+		if result.Err != nil {
+			fmt.Printf("%s: FAILED: %v\n", name, result.Err)
+			failed = append(failed, name)
+			if firstErr == nil {
+				firstErr = result.Err
+			}
+			continue
+		}
+		fmt.Printf("%s: sent\n", name)
+	}
+	if c.Context.Err() != nil {
+		return c.Context.Err()
+	}
+	if len(failed) > 0 {
+		return errs.New(errs.KindOf(firstErr), "%d of %d providers failed: %s", len(failed), len(results), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func ListChannels(c *cli.Context) error {
+	names := ChannelNames()
+	if len(names) == 0 {
+		fmt.Println("No notification channels, add them to the notify section of config.yaml")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHANNEL\tPROVIDER\tTYPE\tDESTINATION")
+	for _, name := range names {
+		targets, err := Channel(name)
+		if err != nil {
+			fmt.Fprintf(w, "%s\t-\t-\tinvalid: %v\n", name, err)
+			continue
+		}
+		for _, target := range targets {
+			destination := "-"
+			if s, ok := target.Provider.(fmt.Stringer); ok {
+				destination = s.String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, target.Name, target.Type, destination)
+		}
+	}
+	return w.Flush()
+}
+
+// ChannelNames returns the names of the channels, for completion.
+func ChannelNames() []string {
+	channels := config.GetConfig().Notify.Channels
+	names := make([]string, 0, len(channels))
+	for name := range channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// discord sends notifications to a webhook of a Discord channel, with the
+// attachments uploaded as files.
+type discord struct {
+	URL string `yaml:"url"`
+	// Username overrides the name of the webhook.
+	Username string `yaml:"username,omitempty"`
+}
+
+func newDiscord(settings map[string]interface{}) (Provider, error) {
+	d := &discord{}
+	if err := Decode(settings, d); err != nil {
+		return nil, err
+	}
+	if d.URL == "" {
+		return nil, errors.New("url is required")
+	}
+	return d, nil
+}
+
+// String hides the webhook, its URL is a secret.
+func (d *discord) String() string {
+	return "webhook"
+}
+
+// Limits of the fields of an embed, in characters. Discord refuses longer
+// ones, so they are truncated. The footer is kept short enough for the
+// whole embed to stay under its limit of 6000 characters.
+const (
+	discordTitleLimit       = 256
+	discordDescriptionLimit = 4096
+	discordFooterLimit      = 1024
+)
+
+// discordColors are the colors of the embed by priority.
+var discordColors = map[Priority]int{
+	PriorityMin:     0x9e9e9e,
+	PriorityLow:     0x9e9e9e,
+	PriorityDefault: 0x439fe0,
+	PriorityHigh:    0xf0a30a,
+	PriorityUrgent:  0xd50000,
+}
+
+func (d *discord) Send(ctx context.Context, n *Notification) error {
+	embed := map[string]interface{}{
+		"title":       truncate(n.Title, discordTitleLimit),
+		"description": truncate(n.Body, discordDescriptionLimit),
+		"color":       discordColors[n.Priority],
+	}
+	if tags := tagsLine(n); tags != "" {
+		embed["footer"] = map[string]string{"text": truncate(tags, discordFooterLimit)}
+	}
+	payload := map[string]interface{}{
+		"embeds": []interface{}{embed},
+	}
+	if d.Username != "" {
+		payload["username"] = d.Username
+	}
+	if len(n.Attachments) == 0 {
+		return request(ctx, "POST", d.URL, nil, payload, nil)
+	}
+
+	// Files are sent in a multipart form, next to the JSON payload
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode the message: %v", err)
+	}
+	if err := form.WriteField("payload_json", string(data)); err != nil {
+		return err
+	}
+	for i, path := range n.Attachments {
+		if err := addFile(form, fmt.Sprintf("files[%d]", i), path); err != nil {
+			return err
+		}
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", form.FormDataContentType())
+	return request(ctx, "POST", d.URL, header, &body, nil)
+}
+
+func addFile(form *multipart.Writer, field, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open the attachment: %v", err)
+	}
+	defer file.Close()
+
+	part, err := form.CreateFormFile(field, filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return fmt.Errorf("failed to read the attachment: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// gotify sends notifications to a Gotify server, with the token of an
+// application.
+type gotify struct {
+	URL   string `yaml:"url"`
+	Token string `yaml:"token"`
+	// Markdown renders the body as Markdown in the clients.
+	Markdown bool `yaml:"markdown,omitempty"`
+}
+
+func newGotify(settings map[string]interface{}) (Provider, error) {
+	g := &gotify{}
+	if err := Decode(settings, g); err != nil {
+		return nil, err
+	}
+	if g.URL == "" || g.Token == "" {
+		return nil, errors.New("url and token are required")
+	}
+	g.URL = strings.TrimSuffix(g.URL, "/")
+	return g, nil
+}
+
+func (g *gotify) String() string {
+	return g.URL
+}
+
+// gotifyPriorities maps priorities to the scale of Gotify, from 0 to 10:
+// the Android app is silent up to 3, and raises high priority
+// notifications from 8.
+var gotifyPriorities = map[Priority]int{
+	PriorityMin:     1,
+	PriorityLow:     3,
+	PriorityDefault: 5,
+	PriorityHigh:    8,
+	PriorityUrgent:  10,
+}
+
+func (g *gotify) Send(ctx context.Context, n *Notification) error {
+	skipAttachments("gotify", n)
+
+	message := map[string]interface{}{
+		"title":    n.Title,
+		"message":  n.Body,
+		"priority": gotifyPriorities[n.Priority],
+	}
+	if tags := tagsLine(n); tags != "" {
+		message["message"] = n.Body + "\n\n" + tags
+	}
+	if g.Markdown {
+		message["extras"] = map[string]interface{}{
+			"client::display": map[string]string{"contentType": "text/markdown"},
+		}
+	}
+
+	header := http.Header{}
+	header.Set("X-Gotify-Key", g.Token)
+	return request(ctx, "POST", g.URL+"/message", header, message, nil)
+}
@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/host"
+)
+
+// request sends a request with the body in, JSON encoded unless it is an
+// io.Reader, and decodes the JSON response into out when it is not nil.
+// Errors never include the URL, those of webhooks are secrets.
+func request(ctx context.Context, method, target string, header http.Header, in interface{}, out interface{}) error {
+	var body io.Reader
+	switch in := in.(type) {
+	case nil:
+	case io.Reader:
+		body = in
+	default:
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode the request: %v", err)
+		}
+		body = bytes.NewReader(data)
+		if header == nil {
+			header = http.Header{}
+		}
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", "application/json")
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", withoutURL(err))
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	client, err := host.HTTPClient("notify")
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errs.Network("failed to send request: %w", withoutURL(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return responseError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return errs.RemoteAPI("failed to decode the response: %v", err)
+	}
+	return nil
+}
+
+// withoutURL returns the error wrapped by err when it is a *url.Error, whose
+// message includes the URL.
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// responseError returns the error of a failed response, with the start of
+// its body, which usually explains the failure.
+func responseError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	reason := resp.Status
+	if text := strings.TrimSpace(string(data)); text != "" {
+		reason = fmt.Sprintf("%s: %s", resp.Status, strings.Join(strings.Fields(text), " "))
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return errs.Auth("the credentials were rejected (%s)", reason)
+	case http.StatusNotFound:
+		return errs.NotFound("not found (%s)", reason)
+	}
+	return errs.RemoteStatus(resp.StatusCode, "the request failed (%s)", reason)
+}
+
+// text returns the title and the body of n as plain text, followed by its
+// tags, for the providers without these fields.
+func text(n *Notification) string {
+	var b strings.Builder
+	if n.Title != "" {
+		b.WriteString(n.Title)
+		b.WriteString("\n\n")
+	}
+	b.WriteString(n.Body)
+	if tags := tagsLine(n); tags != "" {
+		b.WriteString("\n\n")
+		b.WriteString(tags)
+	}
+	return b.String()
+}
+
+func tagsLine(n *Notification) string {
+	if len(n.Tags) == 0 {
+		return ""
+	}
+	return "Tags: " + strings.Join(n.Tags, ", ")
+}
+
+// skipAttachments warns that the provider type cannot send the attachments
+// of n, the notification is sent without them.
+func skipAttachments(providerType string, n *Notification) {
+	if len(n.Attachments) == 0 {
+		return
+	}
+	names := make([]string, len(n.Attachments))
+	for i, path := range n.Attachments {
+		names[i] = filepath.Base(path)
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %s does not support attachments, sent without %s\n", providerType, strings.Join(names, ", "))
+}
@@ -0,0 +1,125 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// matrix sends notifications to a Matrix room, as a user whose access token
+// is given. Attachments are uploaded to the media repository of the
+// homeserver and sent as files.
+type matrix struct {
+	Homeserver  string `yaml:"homeserver"`
+	AccessToken string `yaml:"access_token"`
+	// Room is the id of the room, e.g. !abc:example.com.
+	Room string `yaml:"room"`
+}
+
+func newMatrix(settings map[string]interface{}) (Provider, error) {
+	m := &matrix{}
+	if err := Decode(settings, m); err != nil {
+		return nil, err
+	}
+	if m.Homeserver == "" || m.AccessToken == "" || m.Room == "" {
+		return nil, errors.New("homeserver, access_token and room are required")
+	}
+	m.Homeserver = strings.TrimSuffix(m.Homeserver, "/")
+	return m, nil
+}
+
+func (m *matrix) String() string {
+	return m.Room
+}
+
+func (m *matrix) header() http.Header {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+m.AccessToken)
+	return header
+}
+
+// send sends the event content to the room. The transaction id makes the
+// homeserver ignore the event when a retried request sends it twice.
+func (m *matrix) send(ctx context.Context, txn string, content map[string]interface{}) error {
+	path := fmt.Sprintf("/_matrix/client/v3/rooms/%s/send/m.room.message/%s", url.PathEscape(m.Room), url.PathEscape(txn))
+	return request(ctx, "PUT", m.Homeserver+path, m.header(), content, nil)
+}
+
+func (m *matrix) Send(ctx context.Context, n *Notification) error {
+	plain := text(n)
+	var formatted strings.Builder
+	if n.Title != "" {
+		fmt.Fprintf(&formatted, "<strong>%s</strong><br>", html.EscapeString(n.Title))
+	}
+	formatted.WriteString(strings.ReplaceAll(html.EscapeString(n.Body), "\n", "<br>"))
+	if tags := tagsLine(n); tags != "" {
+		fmt.Fprintf(&formatted, "<br><em>%s</em>", html.EscapeString(tags))
+	}
+
+	msgtype := "m.text"
+	if n.Priority < PriorityDefault {
+		// Notices are not meant to be answered, and bots ignore them
+		msgtype = "m.notice"
+	}
+	txn := fmt.Sprintf("support-%d", time.Now().UnixNano())
+	err := m.send(ctx, txn, map[string]interface{}{
+		"msgtype":        msgtype,
+		"body":           plain,
+		"format":         "org.matrix.custom.html",
+		"formatted_body": formatted.String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, path := range n.Attachments {
+		if err := m.sendFile(ctx, fmt.Sprintf("%s-%d", txn, i), path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendFile uploads the file at path and sends it to the room.
+func (m *matrix) sendFile(ctx context.Context, txn, path string) error {
+	// Read whole, for the length of the upload and its retries
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the attachment: %v", err)
+	}
+
+	name := filepath.Base(path)
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header := m.header()
+	header.Set("Content-Type", contentType)
+
+	var upload struct {
+		ContentURI string `json:"content_uri"`
+	}
+	uploadURL := m.Homeserver + "/_matrix/media/v3/upload?filename=" + url.QueryEscape(name)
+	if err := request(ctx, "POST", uploadURL, header, bytes.NewReader(data), &upload); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+
+	return m.send(ctx, txn, map[string]interface{}{
+		"msgtype": "m.file",
+		"body":    name,
+		"url":     upload.ContentURI,
+		"info": map[string]interface{}{
+			"mimetype": contentType,
+			"size":     len(data),
+		},
+	})
+}
@@ -0,0 +1,231 @@
+// Package notify sends notifications to the channels of config.yaml. A
+// channel fans a notification out to providers such as ntfy, Gotify,
+// Matrix, Slack-compatible webhooks, SMTP and Discord.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/host"
+	"gopkg.in/yaml.v2"
+)
+
+// Priority is the priority of a notification, from 1 to 5 like the
+// priorities of ntfy. Providers map it to their own scale.
+type Priority int
+
+const (
+	PriorityMin Priority = iota + 1
+	PriorityLow
+	PriorityDefault
+	PriorityHigh
+	PriorityUrgent
+)
+
+var priorityNames = map[string]Priority{
+	"min":     PriorityMin,
+	"low":     PriorityLow,
+	"default": PriorityDefault,
+	"high":    PriorityHigh,
+	"max":     PriorityUrgent,
+	"urgent":  PriorityUrgent,
+}
+
+// ParsePriority parses a priority given as a number from 1 to 5 or by name.
+func ParsePriority(s string) (Priority, error) {
+	if p, ok := priorityNames[strings.ToLower(s)]; ok {
+		return p, nil
+	}
+	if p, err := strconv.Atoi(s); err == nil && p >= 1 && p <= 5 {
+		return Priority(p), nil
+	}
+	return 0, fmt.Errorf("invalid priority %q, expected 1-5, min, low, default, high, max or urgent", s)
+}
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityMin:
+		return "min"
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	case PriorityUrgent:
+		return "urgent"
+	}
+	return "default"
+}
+
+// Notification is a notification in the terms shared by the providers.
+type Notification struct {
+	Title string
+	Body  string
+	// Priority is PriorityDefault when unset.
+	Priority Priority
+	Tags     []string
+	// Attachments are paths of local files.
+	Attachments []string
+}
+
+// Provider sends notifications to one destination. Providers describing
+// their destination also implement fmt.Stringer, without secrets, for
+// `notify channels`.
+type Provider interface {
+	Send(ctx context.Context, n *Notification) error
+}
+
+// Factory creates a provider from its settings in a channel. Settings are
+// decoded with Decode.
+type Factory func(settings map[string]interface{}) (Provider, error)
+
+var (
+	factoriesMu sync.Mutex
+	factories   = make(map[string]Factory)
+)
+
+// Register makes the provider type name available to channels. Plugins
+// register theirs by exporting
+//
+//	func Providers() map[string]notify.Factory
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[name] = factory
+}
+
+// Types returns the registered provider types.
+func Types() []string {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	types := make([]string, 0, len(factories))
+	for name := range factories {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func factory(name string) (Factory, bool) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	f, ok := factories[name]
+	return f, ok
+}
+
+// Decode decodes the settings of a provider into v, a struct with yaml tags.
+// Unknown settings are errors, to catch typos.
+func Decode(settings map[string]interface{}, v interface{}) error {
+	data, err := yaml.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	return yaml.UnmarshalStrict(data, v)
+}
+
+// Target is a provider of a channel.
+type Target struct {
+	// Name is the name of the provider in the channel, unique within it.
+	Name     string
+	Type     string
+	Provider Provider
+}
+
+// Channel returns the providers of the channel name.
+func Channel(name string) ([]*Target, error) {
+	providers, ok := config.GetConfig().Notify.Channels[name]
+	if !ok {
+		return nil, errs.NotFound("unknown notification channel %s, see `support notify channels`", name)
+	}
+	if len(providers) == 0 {
+		return nil, errs.Config("notification channel %s has no providers", name)
+	}
+
+	seen := make(map[string]int)
+	targets := make([]*Target, len(providers))
+	for i, p := range providers {
+		f, ok := factory(p.Type)
+		if !ok {
+			return nil, errs.Config("unknown provider type %q in notification channel %s, expected one of %s (plugins may add others)", p.Type, name, strings.Join(Types(), ", "))
+		}
+		provider, err := f(p.Settings)
+		if err != nil {
+			return nil, errs.Config("invalid %s provider in notification channel %s: %v", p.Type, name, err)
+		}
+
+		targetName := p.Name
+		if targetName == "" {
+			targetName = p.Type
+			if seen[p.Type]++; seen[p.Type] > 1 {
+				targetName = fmt.Sprintf("%s#%d", p.Type, seen[p.Type])
+			}
+		}
+		targets[i] = &Target{Name: targetName, Type: p.Type, Provider: provider}
+	}
+	return targets, nil
+}
+
+// Result is the outcome of sending a notification with a provider.
+type Result struct {
+	Channel string
+	Target  *Target
+	Err     error
+}
+
+// Send sends n to every provider of the channels, concurrently. It returns
+// the result of each provider, in the order of the channels, and an error
+// when a channel cannot be resolved.
+func Send(ctx context.Context, channels []string, n *Notification) ([]*Result, error) {
+	if n.Priority == 0 {
+		n.Priority = PriorityDefault
+	}
+	for _, path := range n.Attachments {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, errs.NotFound("failed to read the attachment: %v", err)
+		}
+		if info.IsDir() {
+			return nil, errs.Usage("%s is a directory, only files can be attached", path)
+		}
+	}
+
+	var results []*Result
+	for _, channel := range channels {
+		targets, err := Channel(channel)
+		if err != nil {
+			return nil, err
+		}
+		for _, target := range targets {
+			results = append(results, &Result{Channel: channel, Target: target})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, result := range results {
+		wg.Add(1)
+		go func(result *Result) {
+			defer wg.Done()
+			result.Err = result.Target.Provider.Send(ctx, n)
+		}(result)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+func init() {
+	// The URLs of the webhooks of Slack and Discord are secrets
+	host.HideTracedPaths("notify")
+
+	Register("gotify", newGotify)
+	Register("matrix", newMatrix)
+	Register("slack", newSlack)
+	Register("smtp", newSMTP)
+	Register("discord", newDiscord)
+}
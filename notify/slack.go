@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/url"
+)
+
+// slack sends notifications to an incoming webhook of Slack, or of a
+// compatible service such as Mattermost or Rocket.Chat.
+type slack struct {
+	URL string `yaml:"url"`
+	// Channel and Username override those of the webhook, when the service
+	// allows it.
+	Channel  string `yaml:"channel,omitempty"`
+	Username string `yaml:"username,omitempty"`
+}
+
+func newSlack(settings map[string]interface{}) (Provider, error) {
+	s := &slack{}
+	if err := Decode(settings, s); err != nil {
+		return nil, err
+	}
+	if s.URL == "" {
+		return nil, errors.New("url is required")
+	}
+	return s, nil
+}
+
+// String returns the host of the webhook, its path is a secret.
+func (s *slack) String() string {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return "webhook"
+	}
+	if s.Channel != "" {
+		return u.Host + " " + s.Channel
+	}
+	return u.Host
+}
+
+// slackColors are the colors of the bar of the message by priority.
+var slackColors = map[Priority]string{
+	PriorityMin:     "#9e9e9e",
+	PriorityLow:     "#9e9e9e",
+	PriorityDefault: "#439fe0",
+	PriorityHigh:    "warning",
+	PriorityUrgent:  "danger",
+}
+
+func (s *slack) Send(ctx context.Context, n *Notification) error {
+	skipAttachments("slack", n)
+
+	// A legacy attachment has the title, the colored bar and the footer
+	// supported by the compatible services too
+	attachment := map[string]interface{}{
+		"fallback": text(n),
+		"color":    slackColors[n.Priority],
+		"title":    n.Title,
+		"text":     n.Body,
+	}
+	if tags := tagsLine(n); tags != "" {
+		attachment["footer"] = tags
+	}
+	payload := map[string]interface{}{
+		"attachments": []interface{}{attachment},
+	}
+	if s.Channel != "" {
+		payload["channel"] = s.Channel
+	}
+	if s.Username != "" {
+		payload["username"] = s.Username
+	}
+	return request(ctx, "POST", s.URL, nil, payload, nil)
+}
@@ -0,0 +1,270 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.codycody31.dev/support/errs"
+)
+
+// smtpTimeout bounds a delivery when the context has no deadline.
+const smtpTimeout = time.Minute
+
+// smtpMail sends notifications by email.
+type smtpMail struct {
+	Host string `yaml:"host"`
+	// Port is 587 when unset, or 465 with implicit TLS.
+	Port     int      `yaml:"port,omitempty"`
+	Username string   `yaml:"username,omitempty"`
+	Password string   `yaml:"password,omitempty"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	// TLS is starttls (the default), tls for implicit TLS, or none.
+	TLS string `yaml:"tls,omitempty"`
+}
+
+func newSMTP(settings map[string]interface{}) (Provider, error) {
+	m := &smtpMail{}
+	if err := Decode(settings, m); err != nil {
+		return nil, err
+	}
+	if m.Host == "" || m.From == "" || len(m.To) == 0 {
+		return nil, errors.New("host, from and to are required")
+	}
+	switch m.TLS {
+	case "":
+		m.TLS = "starttls"
+	case "starttls", "tls", "none":
+	default:
+		return nil, fmt.Errorf("unknown tls %q, expected starttls, tls or none", m.TLS)
+	}
+	if m.Port == 0 {
+		m.Port = 587
+		if m.TLS == "tls" {
+			m.Port = 465
+		}
+	}
+	return m, nil
+}
+
+func (m *smtpMail) String() string {
+	return strings.Join(m.To, ", ")
+}
+
+// smtpPriorities are the X-Priority of the messages by priority, 1 being
+// the highest.
+var smtpPriorities = map[Priority]string{
+	PriorityMin:     "5 (Lowest)",
+	PriorityLow:     "4 (Low)",
+	PriorityDefault: "3 (Normal)",
+	PriorityHigh:    "2 (High)",
+	PriorityUrgent:  "1 (Highest)",
+}
+
+func (m *smtpMail) Send(ctx context.Context, n *Notification) error {
+	message, err := m.message(n)
+	if err != nil {
+		return err
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	address := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return errs.Network("failed to connect to %s: %w", address, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(deadline)
+
+	// Close the connection when the command is interrupted, the SMTP
+	// client does not take a context
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	if m.TLS == "tls" {
+		conn = tls.Client(conn, &tls.Config{ServerName: m.Host})
+	}
+	client, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		return m.error(ctx, err)
+	}
+	defer client.Close()
+
+	if m.TLS == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errs.RemoteAPI("%s does not support STARTTLS, set tls to none to send without encryption", address)
+		}
+		if err := client.StartTLS(&tls.Config{ServerName: m.Host}); err != nil {
+			return m.error(ctx, err)
+		}
+	}
+	if m.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host)); err != nil {
+			if ctx.Err() == nil {
+				return errs.Auth("%s rejected the credentials: %v", address, err)
+			}
+			return m.error(ctx, err)
+		}
+	}
+
+	if err := client.Mail(m.From); err != nil {
+		return m.error(ctx, err)
+	}
+	for _, to := range m.To {
+		if err := client.Rcpt(to); err != nil {
+			return m.error(ctx, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return m.error(ctx, err)
+	}
+	if _, err := w.Write(message); err != nil {
+		return m.error(ctx, err)
+	}
+	if err := w.Close(); err != nil {
+		return m.error(ctx, err)
+	}
+	return client.Quit()
+}
+
+func (m *smtpMail) error(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return errs.RemoteAPI("%s:%d refused the message: %v", m.Host, m.Port, err)
+	}
+	return errs.Network("failed to send the email: %w", err)
+}
+
+// message returns the email of n, a multipart message when it has
+// attachments.
+func (m *smtpMail) message(n *Notification) ([]byte, error) {
+	subject := n.Title
+	if subject == "" {
+		subject = firstLine(n.Body)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(m.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", randomID(), m.Host)
+	fmt.Fprintf(&b, "X-Priority: %s\r\n", smtpPriorities[n.Priority])
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	body := n.Body
+	if tags := tagsLine(n); tags != "" {
+		body += "\n\n" + tags
+	}
+
+	if len(n.Attachments) == 0 {
+		b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQuotedPrintable(&b, body); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+
+	form := multipart.NewWriter(&b)
+	fmt.Fprintf(&b, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", form.Boundary())
+	part, err := form.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(part, body); err != nil {
+		return nil, err
+	}
+
+	for _, path := range n.Attachments {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the attachment: %v", err)
+		}
+		name := filepath.Base(path)
+		contentType := mime.TypeByExtension(filepath.Ext(name))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := form.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": name})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Lines of base64 are limited to 76 characters
+		encoded := base64.StdEncoding.EncodeToString(data)
+		for len(encoded) > 76 {
+			fmt.Fprintf(part, "%s\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+		fmt.Fprintf(part, "%s\r\n", encoded)
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, s string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(s, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return truncate(line, 78)
+}
+
+func truncate(s string, n int) string {
+	if len([]rune(s)) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-3]) + "..."
+}
+
+func randomID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"go.codycody31.dev/support/completion"
 	"go.codycody31.dev/support/plugins"
 
 	"github.com/urfave/cli/v2"
@@ -30,5 +31,23 @@ var PluginsCommand = &cli.Command{
 			Usage:  "List all plugins",
 			Action: plugins.ListPlugins,
 		},
+		{
+			Name:   "check",
+			Usage:  "Check that the enabled plugins can be loaded",
+			Hidden: true,
+			Action: plugins.CheckPlugins,
+		},
 	},
 }
+
+func init() {
+	completion.Register("plugins enable", completePluginNames)
+	completion.Register("plugins disable", completePluginNames)
+}
+
+func completePluginNames(command []string, flag, prefix string) []string {
+	if flag != "" {
+		return nil
+	}
+	return plugins.PluginNames()
+}
@@ -1,20 +1,38 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/urfave/cli/v2"
 	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/guard"
+	"go.codycody31.dev/support/host"
+	"go.codycody31.dev/support/plugins"
 )
 
 func Name() string {
 	return "caprover"
 }
 
+func Manifest() plugins.Manifest {
+	return plugins.Manifest{
+		Name:        "caprover",
+		Version:     "0.1.0",
+		Description: "Manage the apps of CapRover servers",
+		Settings: []plugins.Setting{
+			{Key: "server", Type: "string", Description: "URL of the CapRover server, set by `caprover configure`"},
+			{Key: "token", Type: "string", Description: "Authentication token, set by `caprover configure`", Secret: true},
+		},
+	}
+}
+
 func SetupCommands() []*cli.Command {
 	return []*cli.Command{
 		{
@@ -41,7 +59,7 @@ func SetupCommands() []*cli.Command {
 					},
 				},
 				// Bulk delete apps via regex
-				{
+				guard.Destructive(&cli.Command{
 					Name:   "delete",
 					Usage:  "Delete apps",
 					Action: CaproverDelete,
@@ -57,6 +75,7 @@ func SetupCommands() []*cli.Command {
 							Aliases: []string{"e"},
 							Usage:   "Use exact match for app names",
 						},
+						// Kept for compatibility with the global --dry-run
 						&cli.BoolFlag{
 							Name:        "dry-run",
 							Aliases:     []string{"d"},
@@ -64,72 +83,32 @@ func SetupCommands() []*cli.Command {
 							DefaultText: "false",
 						},
 					},
-				},
+				}),
 				{
-					Name:  "list",
-					Usage: "List apps",
-					Action: func(c *cli.Context) error {
-						// Get the CapRover server URL and token
-						server, exists := config.GetPluginSetting("caprover", "server")
-						if !exists {
-							return fmt.Errorf("caprover server not set")
-						}
-						token, exists := config.GetPluginSetting("caprover", "token")
-						if !exists {
-							return fmt.Errorf("caprover token not set")
-						}
-
-						// Create the request
-						req, err := http.NewRequest("GET", server.(string)+"/api/v2/user/apps/appDefinitions", nil)
-						if err != nil {
-							return fmt.Errorf("failed to create request: %v", err)
-						}
-						req.Header.Set("Content-Type", "application/json")
-						req.Header.Set("x-captain-auth", token.(string))
-
-						// Send the request
-						client := &http.Client{}
-						resp, err := client.Do(req)
-						if err != nil {
-							return fmt.Errorf("failed to send request: %v", err)
-						}
-						defer resp.Body.Close()
-
-						// Check if the request was successful
-						if resp.StatusCode != http.StatusOK {
-							return fmt.Errorf("failed to get apps: %v", resp.Status)
-						}
-
-						// Read the response body
-						response := make(map[string]interface{})
-						err = json.NewDecoder(resp.Body).Decode(&response)
-						if err != nil {
-							return fmt.Errorf("failed to read response: %v", err)
-						}
-
-						if response["status"].(float64) == 1106 {
-							return fmt.Errorf(response["description"].(string))
-						}
-
-						// Loop through the apps
-						apps := response["data"].(map[string]interface{})["appDefinitions"].([]interface{})
-						totalApps := len(apps)
-
-						fmt.Printf("Found %d apps\n", totalApps)
-
-						for _, app := range apps {
-							appName := app.(map[string]interface{})["appName"].(string)
-							fmt.Println(appName)
-						}
-
-						return nil
-					},
+					Name:   "list",
+					Usage:  "List apps",
+					Action: CaproverList,
 				},
 			},
 		},
 	}
 }
 
+func CaproverList(c *cli.Context) error {
+	apps, err := fetchAppNames(c.Context)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Found %d apps\n", len(apps))
+
+	for _, appName := range apps {
+		fmt.Println(appName)
+	}
+
+	return nil
+}
+
 func CaproverConfigure(c *cli.Context) error {
 	url := c.String("url")
 	password := c.String("password")
@@ -141,23 +120,26 @@ func CaproverConfigure(c *cli.Context) error {
 	body := strings.NewReader(`{"password": "` + password + `"}`)
 
 	// Create the request
-	req, err := http.NewRequest("POST", url+"/api/v2/login", body)
+	req, err := http.NewRequestWithContext(c.Context, "POST", url+"/api/v2/login", body)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %v", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	// Send the request
-	client := &http.Client{}
+	client, err := host.HTTPClient("caprover")
+	if err != nil {
+		return err
+	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %v", err)
+		return errs.Network("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Check if the request was successful
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to login: %v", resp.Status)
+		return errs.RemoteStatus(resp.StatusCode, "failed to login: %v", resp.Status)
 	}
 
 	// Read the response body
@@ -166,6 +148,9 @@ func CaproverConfigure(c *cli.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to read response: %v", err)
 	}
+	if err := responseError(response); err != nil {
+		return err
+	}
 	token := response["data"].(map[string]interface{})["token"].(string)
 
 	// Store the CapRover server URL and token
@@ -186,38 +171,97 @@ func CaproverConfigure(c *cli.Context) error {
 func CaproverDelete(c *cli.Context) error {
 	regex := c.String("regex")
 	exact := c.Bool("exact")
-	dryRun := c.Bool("dry-run")
-	matched := 0
+	dryRun := guard.DryRun(c)
 
 	// Get the CapRover server URL and token
 	server, exists := config.GetPluginSetting("caprover", "server")
 	if !exists {
-		return fmt.Errorf("caprover server not set")
+		return errs.Config("caprover server not set, run `support caprover configure`")
 	}
 	token, exists := config.GetPluginSetting("caprover", "token")
 	if !exists {
-		return fmt.Errorf("caprover token not set")
+		return errs.Config("caprover token not set, run `support caprover configure`")
 	}
 
+	apps, err := fetchAppNames(c.Context)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Found %d apps\n", len(apps))
+
+	// Select the apps matching the regex or exact name
+	var matching []string
+	var pattern *regexp.Regexp
+	if !exact {
+		pattern, err = regexp.Compile(regex)
+		if err != nil {
+			return errs.Usage("failed to compile regex: %v", err)
+		}
+	}
+	for _, appName := range apps {
+		if (exact && appName == regex) || (!exact && pattern.MatchString(appName)) {
+			matching = append(matching, appName)
+		}
+	}
+
+	if len(matching) == 0 {
+		fmt.Println("No apps matched the regex")
+		return nil
+	}
+
+	if dryRun {
+		for _, appName := range matching {
+			fmt.Printf("Would delete app: %s\n", appName)
+		}
+		fmt.Printf("Would delete %d apps\n", len(matching))
+		return nil
+	}
+
+	if err := guard.Confirm(c, "delete", matching); err != nil {
+		return err
+	}
+
+	for i, appName := range matching {
+		if err := deleteApp(c.Context, server.(string), token.(string), appName); err != nil {
+			if c.Context.Err() != nil {
+				// Interrupted, tell what was done so the run can be resumed
+				fmt.Printf("Interrupted after deleting %d of %d apps, not deleted: %s\n", i, len(matching), strings.Join(matching[i:], ", "))
+			}
+			return err
+		}
+
+		fmt.Printf("Deleted app: %s\n", appName)
+	}
+
+	fmt.Printf("Deleted %d apps\n", len(matching))
+	return nil
+}
+
+// deleteApp deletes the app appName of the server.
+func deleteApp(ctx context.Context, server, token, appName string) error {
 	// Create the request
-	req, err := http.NewRequest("GET", server.(string)+"/api/v2/user/apps/appDefinitions", nil)
+	req, err := http.NewRequestWithContext(ctx, "POST", server+"/api/v2/user/apps/appDefinitions/delete", strings.NewReader(`{"appName": "`+appName+`"}`))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %v", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-captain-auth", token.(string))
+	req.Header.Set("x-captain-auth", token)
 
 	// Send the request
-	client := &http.Client{}
+	client, err := host.HTTPClient("caprover")
+	if err != nil {
+		return err
+	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %v", err)
+		return errs.Network("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Check if the request was successful
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to get apps: %v", resp.Status)
+		return errs.RemoteStatus(resp.StatusCode, "failed to delete app: %v", resp.Status)
 	}
 
 	// Read the response body
@@ -226,71 +270,98 @@ func CaproverDelete(c *cli.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to read response: %v", err)
 	}
+	return responseError(response)
+}
 
-	if response["status"].(float64) == 1106 {
-		return fmt.Errorf(response["description"].(string))
-	}
-
-	// Loop through the apps
-	apps := response["data"].(map[string]interface{})["appDefinitions"].([]interface{})
-	totalApps := len(apps)
-
-	fmt.Printf("Found %d apps\n", totalApps)
+// Complete completes the --regex flag of delete with the app names.
+func Complete(command []string, flag, prefix string) []string {
+	if len(command) == 2 && command[1] == "delete" && flag == "regex" {
+		// Completion must not hang the shell if the server does not answer
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
 
-	for _, app := range apps {
-		appName := app.(map[string]interface{})["appName"].(string)
-
-		// Check if the app matches the regex or exact name
-		match := false
-		if exact {
-			match = appName == regex
-		} else {
-			matched, err := regexp.MatchString(regex, appName)
-			if err != nil {
-				return fmt.Errorf("failed to compile regex: %v", err)
-			}
-			match = matched
-		}
-
-		if !match {
-			continue
-		}
+		apps, _ := fetchAppNames(ctx)
+		return apps
+	}
+	return nil
+}
 
-		matched++
+// fetchAppNames returns the names of the apps of the configured server.
+func fetchAppNames(ctx context.Context) ([]string, error) {
+	// Get the CapRover server URL and token
+	server, exists := config.GetPluginSetting("caprover", "server")
+	if !exists {
+		return nil, errs.Config("caprover server not set, run `support caprover configure`")
+	}
+	token, exists := config.GetPluginSetting("caprover", "token")
+	if !exists {
+		return nil, errs.Config("caprover token not set, run `support caprover configure`")
+	}
 
-		if dryRun {
-			fmt.Printf("Would delete app: %s\n", appName)
-			continue
-		}
+	// Create the request
+	req, err := http.NewRequestWithContext(ctx, "GET", server.(string)+"/api/v2/user/apps/appDefinitions", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-captain-auth", token.(string))
 
-		// Create the request
-		req, err := http.NewRequest("POST", server.(string)+"/api/v2/user/apps/appDefinitions/delete", strings.NewReader(`{"appName": "`+appName+`"}`))
-		if err != nil {
-			return fmt.Errorf("failed to create request: %v", err)
-		}
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("x-captain-auth", token.(string))
+	// Send the request
+	client, err := host.HTTPClient("caprover")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errs.Network("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
 
-		// Send the request
-		resp, err := client.Do(req)
-		if err != nil {
-			return fmt.Errorf("failed to send request: %v", err)
-		}
-		defer resp.Body.Close()
+	// Check if the request was successful
+	if resp.StatusCode != http.StatusOK {
+		return nil, errs.RemoteStatus(resp.StatusCode, "failed to get apps: %v", resp.Status)
+	}
 
-		// Check if the request was successful
-		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("failed to delete app: %v", resp.Status)
-		}
+	// Read the response body
+	response := make(map[string]interface{})
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
 
-		fmt.Printf("Deleted app: %s\n", appName)
+	if err := responseError(response); err != nil {
+		return nil, err
 	}
 
-	if matched == 0 {
-		fmt.Println("No apps matched the regex")
-	} else {
-		fmt.Printf("Deleted %d apps\n", matched)
+	apps := response["data"].(map[string]interface{})["appDefinitions"].([]interface{})
+	names := make([]string, 0, len(apps))
+	for _, app := range apps {
+		names = append(names, app.(map[string]interface{})["appName"].(string))
 	}
+	return names, nil
+}
 
-	return nil
+// CapRover answers with HTTP 200 and reports failures in the status field of
+// the body.
+const (
+	statusOK               = 100
+	statusOKDeployStarted  = 101
+	statusOKPartialSuccess = 102
+	statusAuthTokenInvalid = 1105
+	statusWrongPassword    = 1106
+	statusNotAuthorized    = 1107
+)
+
+func responseError(response map[string]interface{}) error {
+	status, _ := response["status"].(float64)
+	description, _ := response["description"].(string)
+
+	switch int(status) {
+	case statusOK, statusOKDeployStarted, statusOKPartialSuccess:
+		return nil
+	case statusAuthTokenInvalid, statusWrongPassword, statusNotAuthorized:
+		return errs.Auth("caprover: %s", description)
+	default:
+		return errs.RemoteAPI("caprover: %s (status %d)", description, int(status))
+	}
 }
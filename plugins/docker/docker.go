@@ -1,16 +1,30 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"os/exec"
+	"strings"
+	"time"
 
 	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/guard"
+	"go.codycody31.dev/support/host"
+	"go.codycody31.dev/support/plugins"
 )
 
 func Name() string {
 	return "docker"
 }
 
+func Manifest() plugins.Manifest {
+	return plugins.Manifest{
+		Name:        "docker",
+		Version:     "0.1.0",
+		Description: "Manage the Docker containers of this host",
+	}
+}
+
 func SetupCommands() []*cli.Command {
 	return []*cli.Command{
 		{
@@ -22,7 +36,7 @@ func SetupCommands() []*cli.Command {
 					Usage:  "List all running Docker containers",
 					Action: DockerList,
 				},
-				{
+				guard.Destructive(&cli.Command{
 					Name:   "stop",
 					Usage:  "Stop a Docker container",
 					Action: DockerStop,
@@ -34,17 +48,16 @@ func SetupCommands() []*cli.Command {
 							Required: true,
 						},
 					},
-				},
+				}),
 			},
 		},
 	}
 }
 
 func DockerList(c *cli.Context) error {
-	cmd := exec.Command("docker", "ps")
-	output, err := cmd.CombinedOutput()
+	output, err := host.CombinedOutput(c.Context, "docker", "ps")
 	if err != nil {
-		return fmt.Errorf("failed to list containers: %v", err)
+		return fmt.Errorf("failed to list containers: %w", err)
 	}
 	fmt.Println(string(output))
 	return nil
@@ -52,11 +65,39 @@ func DockerList(c *cli.Context) error {
 
 func DockerStop(c *cli.Context) error {
 	container := c.String("container")
-	cmd := exec.Command("docker", "stop", container)
-	output, err := cmd.CombinedOutput()
+	if guard.DryRun(c) {
+		fmt.Printf("Would stop container: %s\n", container)
+		return nil
+	}
+	if err := guard.Confirm(c, "stop", []string{container}); err != nil {
+		return err
+	}
+
+	output, err := host.CombinedOutput(c.Context, "docker", "stop", container)
 	if err != nil {
-		return fmt.Errorf("failed to stop container %s: %v", container, err)
+		if strings.Contains(string(output), "No such container") {
+			return errs.NotFound("container %s not found", container)
+		}
+		return fmt.Errorf("failed to stop container %s: %w", container, err)
 	}
 	fmt.Println(string(output))
 	return nil
 }
+
+// Complete completes the --container flag of stop with the names of the
+// running containers.
+func Complete(command []string, flag, prefix string) []string {
+	if len(command) != 2 || command[1] != "stop" || flag != "container" {
+		return nil
+	}
+
+	// Completion must not hang the shell if the daemon does not answer
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	output, err := host.Output(ctx, "docker", "ps", "--format", "{{.Names}}")
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(output))
+}
@@ -5,11 +5,75 @@ import (
 	"os"
 	"path/filepath"
 	"plugin"
+	"strings"
+	"time"
 
 	"github.com/urfave/cli/v2"
 	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/notify"
 )
 
+// Plugin describes a plugin loaded by LoadPlugins.
+type Plugin struct {
+	Name     string
+	Version  string
+	Path     string
+	Manifest Manifest
+	// LoadTime is how long opening the plugin and setting up its commands
+	// took.
+	LoadTime time.Duration
+	Commands []*cli.Command
+	// Complete returns completion candidates for the plugin's commands, it
+	// is nil if the plugin does not export a Complete function.
+	Complete func(command []string, flag, prefix string) []string
+}
+
+var loaded []*Plugin
+
+// Loaded returns the plugins loaded by LoadPlugins.
+func Loaded() []*Plugin {
+	return loaded
+}
+
+// ForCommand returns the plugin that added the top level command name, or nil
+// if the command is built in.
+func ForCommand(name string) *Plugin {
+	for _, p := range loaded {
+		for _, cmd := range p.Commands {
+			if cmd.HasName(name) {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+// Failure is a plugin that could not be loaded.
+type Failure struct {
+	Name string
+	Path string
+	Err  error
+}
+
+var failures []*Failure
+
+// Failures returns the enabled plugins LoadPlugins could not load.
+func Failures() []*Failure {
+	return failures
+}
+
+// FailureFor returns the failure of the plugin adding the top level command
+// name, assuming plugins are named after their command, or nil.
+func FailureFor(name string) *Failure {
+	for _, failure := range failures {
+		if failure.Name == name {
+			return failure
+		}
+	}
+	return nil
+}
+
 func LoadPlugins(app *cli.App) {
 	for _, pluginsDir := range config.GetConfig().PluginDirs {
 		err := filepath.Walk(pluginsDir, func(path string, info os.FileInfo, err error) error {
@@ -24,32 +88,100 @@ func LoadPlugins(app *cli.App) {
 				pluginName = pluginName[:len(pluginName)-3]
 
 				if config.GetConfig().Plugins[pluginName] {
-					p, err := plugin.Open(path)
+					start := time.Now()
+					loadedPlugin, err := loadPlugin(path, pluginName)
 					if err != nil {
-						return fmt.Errorf("failed to load plugin %s: %v", pluginName, err)
+						// Keep loading the other plugins
+						failures = append(failures, &Failure{Name: pluginName, Path: path, Err: err})
+						fmt.Fprintf(os.Stderr, "Error loading plugin: %v\n", err)
+						return nil
 					}
 
-					symbol, err := p.Lookup("SetupCommands")
-					if err != nil {
-						return fmt.Errorf("plugin %s does not implement SetupCommands: %v", pluginName, err)
-					}
-
-					setupCommands, ok := symbol.(func() []*cli.Command)
-					if !ok {
-						return fmt.Errorf("invalid SetupCommands signature in plugin %s", pluginName)
-					}
-
-					commands := setupCommands()
-					app.Commands = append(app.Commands, commands...)
+					loadedPlugin.LoadTime = time.Since(start)
+					app.Commands = append(app.Commands, loadedPlugin.Commands...)
+					loaded = append(loaded, loadedPlugin)
 				}
 			}
 			return nil
 		})
 
 		if err != nil {
-			fmt.Printf("Error loading plugins from directory %s: %v\n", pluginsDir, err)
+			fmt.Fprintf(os.Stderr, "Error loading plugins from directory %s: %v\n", pluginsDir, err)
+		}
+	}
+}
+
+func loadPlugin(path, pluginName string) (*Plugin, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, errs.PluginLoad("failed to load plugin %s: %v", pluginName, err)
+	}
+
+	symbol, err := p.Lookup("SetupCommands")
+	if err != nil {
+		return nil, errs.PluginLoad("plugin %s does not implement SetupCommands: %v", pluginName, err)
+	}
+
+	setupCommands, ok := symbol.(func() []*cli.Command)
+	if !ok {
+		return nil, errs.PluginLoad("invalid SetupCommands signature in plugin %s", pluginName)
+	}
+
+	// Manifest is optional, plugins without it may export Version
+	manifest := Manifest{Name: pluginName}
+	if symbol, err := p.Lookup("Manifest"); err == nil {
+		manifestFunc, ok := symbol.(func() Manifest)
+		if !ok {
+			return nil, errs.PluginLoad("invalid Manifest signature in plugin %s", pluginName)
+		}
+		manifest = manifestFunc()
+	} else if symbol, err := p.Lookup("Version"); err == nil {
+		if versionFunc, ok := symbol.(func() string); ok {
+			manifest.Version = versionFunc()
+		}
+	}
+
+	// Complete is optional
+	var complete func([]string, string, string) []string
+	if symbol, err := p.Lookup("Complete"); err == nil {
+		complete, _ = symbol.(func([]string, string, string) []string)
+	}
+
+	// Providers is optional, it adds notification provider types
+	if symbol, err := p.Lookup("Providers"); err == nil {
+		providers, ok := symbol.(func() map[string]notify.Factory)
+		if !ok {
+			return nil, errs.PluginLoad("invalid Providers signature in plugin %s", pluginName)
 		}
+		for name, factory := range providers() {
+			notify.Register(name, factory)
+		}
+	}
+
+	return &Plugin{
+		Name:     pluginName,
+		Version:  manifest.Version,
+		Path:     path,
+		Manifest: manifest,
+		Commands: setupCommands(),
+		Complete: complete,
+	}, nil
+}
+
+// CheckPlugins reports whether the enabled plugins could be loaded by this
+// binary. It is run by self-update on the new binary before installing it.
+func CheckPlugins(c *cli.Context) error {
+	for _, p := range loaded {
+		fmt.Printf("ok      %s %s\n", p.Name, p.Version)
+	}
+	for _, failure := range failures {
+		fmt.Printf("FAILED  %s: %v\n", failure.Name, failure.Err)
+	}
+
+	if len(failures) > 0 {
+		return errs.PluginLoad("%d plugin(s) failed to load", len(failures))
 	}
+	return nil
 }
 
 func RegisterPluginDir(c *cli.Context) error {
@@ -131,3 +263,16 @@ func ListPlugins(c *cli.Context) error {
 	}
 	return nil
 }
+
+// PluginNames returns the names of the plugins found in the plugin
+// directories, enabled or not.
+func PluginNames() []string {
+	var names []string
+	for _, pluginsDir := range config.GetConfig().PluginDirs {
+		matches, _ := filepath.Glob(filepath.Join(pluginsDir, "*.so"))
+		for _, match := range matches {
+			names = append(names, strings.TrimSuffix(filepath.Base(match), ".so"))
+		}
+	}
+	return names
+}
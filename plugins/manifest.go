@@ -0,0 +1,25 @@
+package plugins
+
+// Manifest describes a plugin. Plugins provide it by exporting
+//
+//	func Manifest() plugins.Manifest
+//
+// Plugins without it are described by their optional Version function.
+type Manifest struct {
+	Name        string
+	Version     string
+	Description string
+	// Settings are the keys the plugin reads from its plugin settings.
+	Settings []Setting
+}
+
+// Setting describes a key of the settings of a plugin.
+type Setting struct {
+	Key string
+	// Type is the YAML type of the value, such as string, bool or int.
+	Type        string
+	Default     string
+	Description string
+	// Secret marks credentials, which are not shown.
+	Secret bool
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/guard"
+)
+
+// accessToken is an access token of an account.
+type accessToken struct {
+	Token      string `json:"token"`
+	Label      string `json:"label,omitempty"`
+	LastAccess int64  `json:"last_access,omitempty"`
+	LastOrigin string `json:"last_origin,omitempty"`
+	// Expires is 0 for tokens that never expire.
+	Expires int64 `json:"expires,omitempty"`
+}
+
+// reservation is a topic reserved by an account, with the access of the
+// other users to it.
+type reservation struct {
+	Topic    string `json:"topic"`
+	Everyone string `json:"everyone"`
+}
+
+// permissions are the accesses of the other users to a reserved topic.
+var permissions = []string{"deny-all", "read-only", "write-only", "read-write"}
+
+// accountServer returns the server called name, or the server of topic, and
+// checks that it has the credentials of an account: the account API is not
+// available to anonymous users.
+func accountServer(name, topic string) (*server, error) {
+	s, err := resolveServer(name, topic)
+	if err != nil {
+		return nil, err
+	}
+	if s.Token == "" && s.Username == "" {
+		return nil, errs.Auth("%s has no credentials, add them with `support ntfy servers add` or `support ntfy configure`", s)
+	}
+	return s, nil
+}
+
+// userAccount returns the account of s, which must not be the anonymous
+// account of a server ignoring the credentials.
+func (s *server) userAccount(ctx context.Context) (*account, error) {
+	a, err := s.account(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if a.Username == "" || a.Username == "*" {
+		return nil, errs.Auth("%s ignored the credentials, is access control enabled?", s)
+	}
+	return a, nil
+}
+
+// call sends a request with the JSON body in to the account API of s, and
+// decodes the response into out when it is not nil.
+func (s *server) call(ctx context.Context, method, path string, in interface{}, header map[string]string, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode the request: %v", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := s.newRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	return s.do(req, out)
+}
+
+// parseExpiry returns the Unix time after the duration s, a Go duration or a
+// number of days such as 30d, or 0 for never.
+func parseExpiry(s string, now time.Time) (int64, error) {
+	if s == "" || s == "never" {
+		return 0, nil
+	}
+	if strings.HasSuffix(s, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid expiry %q, expected a duration such as 12h or 30d, or never", s)
+		}
+		return now.AddDate(0, 0, n).Unix(), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid expiry %q, expected a duration such as 12h or 30d, or never", s)
+	}
+	return now.Add(d).Unix(), nil
+}
+
+func formatUnix(t int64, zero string) string {
+	if t == 0 {
+		return zero
+	}
+	return time.Unix(t, 0).Format("2006-01-02 15:04")
+}
+
+func CreateToken(c *cli.Context) error {
+	format := c.String("format")
+	if format != "text" && format != "json" {
+		return errs.Usage("unknown format %q, expected text or json", format)
+	}
+	expires, err := parseExpiry(c.String("expires"), time.Now())
+	if err != nil {
+		return errs.Usage("%v", err)
+	}
+
+	s, err := accountServer(c.String("server"), "")
+	if err != nil {
+		return err
+	}
+	token := &accessToken{}
+	request := map[string]interface{}{"label": c.String("label")}
+	if expires > 0 {
+		request["expires"] = expires
+	}
+	if err := s.call(c.Context, "POST", "/v1/account/token", request, nil, token); err != nil {
+		return err
+	}
+
+	if format == "json" {
+		return printJSON(token)
+	}
+	fmt.Printf("Created access token %s on %s, expiring %s\n", token.Token, s, formatUnix(token.Expires, "never"))
+	return nil
+}
+
+func ListTokens(c *cli.Context) error {
+	format := c.String("format")
+	if format != "text" && format != "json" {
+		return errs.Usage("unknown format %q, expected text or json", format)
+	}
+
+	s, err := accountServer(c.String("server"), "")
+	if err != nil {
+		return err
+	}
+	account, err := s.userAccount(c.Context)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		if account.Tokens == nil {
+			account.Tokens = []*accessToken{}
+		}
+		return printJSON(account.Tokens)
+	}
+	if len(account.Tokens) == 0 {
+		fmt.Println("No access tokens")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TOKEN\tLABEL\tLAST ACCESS\tORIGIN\tEXPIRES")
+	for _, t := range account.Tokens {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", t.Token, dash(t.Label), formatUnix(t.LastAccess, "-"), dash(t.LastOrigin), formatUnix(t.Expires, "never"))
+	}
+	return w.Flush()
+}
+
+func RevokeTokens(c *cli.Context) error {
+	if !c.Args().Present() {
+		return errs.Usage("expected the access tokens to revoke")
+	}
+	tokens := c.Args().Slice()
+	for _, token := range tokens {
+		// Without a token the server revokes the token of the request
+		if strings.TrimSpace(token) == "" {
+			return errs.Usage("an access token cannot be empty")
+		}
+	}
+	s, err := accountServer(c.String("server"), "")
+	if err != nil {
+		return err
+	}
+
+	if guard.DryRun(c) {
+		fmt.Printf("Would revoke access tokens on %s: %s\n", s, strings.Join(tokens, ", "))
+		return nil
+	}
+	if err := guard.Confirm(c, "revoke", tokens); err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		if token == s.Token {
+			fmt.Fprintf(os.Stderr, "Warning: %s is the token of %s, which will no longer be able to authenticate\n", token, s)
+		}
+		// Without X-Token, the token authenticating the request is revoked
+		if err := s.call(c.Context, "DELETE", "/v1/account/token", nil, map[string]string{"X-Token": token}, nil); err != nil {
+			return fmt.Errorf("failed to revoke %s: %w", token, err)
+		}
+		fmt.Println("Revoked access token", token)
+	}
+	return nil
+}
+
+func AddReservation(c *cli.Context) error {
+	topic := c.Args().First()
+	if strings.TrimSpace(topic) == "" {
+		return errs.Usage("a topic is required")
+	}
+	if c.Args().Len() > 1 {
+		return errs.Usage("unexpected arguments %q, flags must come before the topic", c.Args().Tail())
+	}
+	everyone := c.String("everyone")
+	if !contains(permissions, everyone) {
+		return errs.Usage("unknown permission %q, expected %s", everyone, strings.Join(permissions, ", "))
+	}
+
+	s, err := accountServer(c.String("server"), topic)
+	if err != nil {
+		return err
+	}
+	r := &reservation{Topic: topic, Everyone: everyone}
+	if err := s.call(c.Context, "POST", "/v1/account/reservation", r, nil, nil); err != nil {
+		return err
+	}
+	fmt.Printf("Reserved %s on %s, %s for everyone else\n", topic, s, everyone)
+	return nil
+}
+
+func ListReservations(c *cli.Context) error {
+	format := c.String("format")
+	if format != "text" && format != "json" {
+		return errs.Usage("unknown format %q, expected text or json", format)
+	}
+
+	s, err := accountServer(c.String("server"), "")
+	if err != nil {
+		return err
+	}
+	account, err := s.userAccount(c.Context)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		if account.Reservations == nil {
+			account.Reservations = []*reservation{}
+		}
+		return printJSON(account.Reservations)
+	}
+	if len(account.Reservations) == 0 {
+		fmt.Println("No reserved topics")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TOPIC\tEVERYONE")
+	for _, r := range account.Reservations {
+		fmt.Fprintf(w, "%s\t%s\n", r.Topic, r.Everyone)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if limit := account.Limits.Reservations; limit > 0 {
+		fmt.Printf("%d of %d reservations used\n", len(account.Reservations), limit)
+	}
+	return nil
+}
+
+func RemoveReservations(c *cli.Context) error {
+	if !c.Args().Present() {
+		return errs.Usage("expected the topics to release")
+	}
+
+	topics := c.Args().Slice()
+	for _, topic := range topics {
+		if strings.TrimSpace(topic) == "" {
+			return errs.Usage("a topic cannot be empty")
+		}
+	}
+	servers := make([]*server, len(topics))
+	targets := make([]string, len(topics))
+	for i, topic := range topics {
+		s, err := accountServer(c.String("server"), topic)
+		if err != nil {
+			return err
+		}
+		servers[i] = s
+		targets[i] = fmt.Sprintf("%s on %s", topic, s)
+	}
+	if c.Bool("delete-messages") {
+		for i := range targets {
+			targets[i] += ", deleting its cached messages"
+		}
+	}
+
+	if guard.DryRun(c) {
+		fmt.Println("Would release reserved topics:", strings.Join(targets, "; "))
+		return nil
+	}
+	if err := guard.Confirm(c, "release", targets); err != nil {
+		return err
+	}
+
+	header := map[string]string{}
+	if c.Bool("delete-messages") {
+		header["X-Delete-Messages"] = "true"
+	}
+	for i, topic := range topics {
+		if err := servers[i].call(c.Context, "DELETE", "/v1/account/reservation/"+url.PathEscape(topic), nil, header, nil); err != nil {
+			return fmt.Errorf("failed to release %s: %w", topic, err)
+		}
+		fmt.Println("Released reserved topic", topic)
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/guard"
+)
+
+// fakeAccount is the account API of an ntfy server, for the token of
+// testToken.
+type fakeAccount struct {
+	mu           sync.Mutex
+	tokens       []*accessToken
+	reservations []*reservation
+	// deleted lists the topics released with their messages deleted.
+	deleted []string
+	// unsafe records requests that would have revoked the token in use.
+	unsafe int
+}
+
+const testToken = "tk_test"
+
+func (a *fakeAccount) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer "+testToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"code":40101,"http":401,"error":"unauthorized"}`))
+		return
+	}
+
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/v1/account":
+		json.NewEncoder(w).Encode(&account{Username: "alice", Tokens: a.tokens, Reservations: a.reservations})
+	case r.Method == "POST" && r.URL.Path == "/v1/account/token":
+		var req struct {
+			Label   string `json:"label"`
+			Expires int64  `json:"expires"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		token := &accessToken{Token: "tk_new" + req.Label, Label: req.Label, Expires: req.Expires}
+		a.tokens = append(a.tokens, token)
+		json.NewEncoder(w).Encode(token)
+	case r.Method == "DELETE" && r.URL.Path == "/v1/account/token":
+		revoked := r.Header.Get("X-Token")
+		if revoked == "" {
+			a.unsafe++
+			revoked = testToken
+		}
+		for i, t := range a.tokens {
+			if t.Token == revoked {
+				a.tokens = append(a.tokens[:i], a.tokens[i+1:]...)
+				break
+			}
+		}
+		w.Write([]byte(`{"success":true}`))
+	case r.Method == "POST" && r.URL.Path == "/v1/account/reservation":
+		res := &reservation{}
+		json.NewDecoder(r.Body).Decode(res)
+		a.reservations = append(a.reservations, res)
+		w.Write([]byte(`{"success":true}`))
+	case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, "/v1/account/reservation/"):
+		topic := strings.TrimPrefix(r.URL.Path, "/v1/account/reservation/")
+		for i, res := range a.reservations {
+			if res.Topic == topic {
+				a.reservations = append(a.reservations[:i], a.reservations[i+1:]...)
+				if r.Header.Get("X-Delete-Messages") == "true" {
+					a.deleted = append(a.deleted, topic)
+				}
+				w.Write([]byte(`{"success":true}`))
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"code":40401,"http":404,"error":"page not found"}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"code":40401,"http":404,"error":"page not found"}`))
+	}
+}
+
+// TestMain points HOME at a temporary directory, so that the tests never
+// read or write the config and state of the user.
+func TestMain(m *testing.M) {
+	home, err := os.MkdirTemp("", "support-ntfy-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Setenv("HOME", home)
+	config.Reload()
+
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
+// useServer makes url, with testToken, the server of the ntfy commands.
+func useServer(t *testing.T, url string) {
+	t.Helper()
+	cfg := config.GetConfig()
+	previous := cfg.PluginSettings
+	cfg.PluginSettings = map[string]map[string]interface{}{
+		"ntfy": {"server": url, "access-token": testToken},
+	}
+	guard.Set(false, true)
+	t.Cleanup(func() {
+		cfg.PluginSettings = previous
+		guard.Set(false, false)
+	})
+}
+
+// runNtfy runs the ntfy command with args and returns its output.
+func runNtfy(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	app := &cli.App{
+		Name:           "support",
+		Commands:       SetupCommands(),
+		ExitErrHandler: func(*cli.Context, error) {},
+	}
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	output := make(chan string)
+	go func() {
+		var out strings.Builder
+		buf := make([]byte, 4096)
+		for {
+			n, err := r.Read(buf)
+			out.Write(buf[:n])
+			if err != nil {
+				break
+			}
+		}
+		output <- out.String()
+	}()
+
+	err = app.Run(append([]string{"support", "ntfy"}, args...))
+	w.Close()
+	os.Stdout = stdout
+	return <-output, err
+}
+
+func TestTokens(t *testing.T) {
+	fake := &fakeAccount{tokens: []*accessToken{{Token: testToken, Label: "laptop"}}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	useServer(t, server.URL)
+
+	if _, err := runNtfy(t, "tokens", "create", "--label", "ci", "--expires", "30d"); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if len(fake.tokens) != 2 || fake.tokens[1].Label != "ci" || fake.tokens[1].Expires == 0 {
+		t.Fatalf("tokens after create are %+v", fake.tokens)
+	}
+
+	out, err := runNtfy(t, "tokens", "list", "--format", "json")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	var listed []*accessToken
+	if err := json.Unmarshal([]byte(out), &listed); err != nil {
+		t.Fatalf("list printed %q: %v", out, err)
+	}
+	if len(listed) != 2 || listed[0].Token != testToken || listed[1].Token != "tk_newci" {
+		t.Errorf("listed tokens %+v", listed)
+	}
+
+	if _, err := runNtfy(t, "tokens", "revoke", "tk_newci"); err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+	if len(fake.tokens) != 1 || fake.tokens[0].Token != testToken {
+		t.Errorf("tokens after revoke are %+v", fake.tokens)
+	}
+
+	for _, token := range []string{"", " "} {
+		if _, err := runNtfy(t, "tokens", "revoke", token); err == nil {
+			t.Errorf("revoke %q succeeded", token)
+		}
+	}
+	if _, err := runNtfy(t, "tokens", "revoke", "tk_other", ""); err == nil {
+		t.Error("revoke with an empty token succeeded")
+	}
+	if fake.unsafe != 0 || len(fake.tokens) != 1 {
+		t.Errorf("an empty token revoked the token in use")
+	}
+}
+
+func TestReservations(t *testing.T) {
+	fake := &fakeAccount{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	useServer(t, server.URL)
+
+	if _, err := runNtfy(t, "reservations", "add", "--everyone", "read-only", "alerts"); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	if _, err := runNtfy(t, "reservations", "add", "builds"); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	if _, err := runNtfy(t, "reservations", "add", "--everyone", "everything", "x"); err == nil {
+		t.Error("add with an unknown permission succeeded")
+	}
+	for _, topic := range []string{"", " "} {
+		if _, err := runNtfy(t, "reservations", "add", topic); err == nil {
+			t.Errorf("add of topic %q succeeded", topic)
+		}
+	}
+
+	out, err := runNtfy(t, "reservations", "list", "--format", "json")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	var listed []*reservation
+	if err := json.Unmarshal([]byte(out), &listed); err != nil {
+		t.Fatalf("list printed %q: %v", out, err)
+	}
+	expected := []reservation{{"alerts", "read-only"}, {"builds", "deny-all"}}
+	if len(listed) != len(expected) {
+		t.Fatalf("listed reservations %+v, expected %+v", listed, expected)
+	}
+	for i, r := range listed {
+		if *r != expected[i] {
+			t.Errorf("reservation %d is %+v, expected %+v", i, *r, expected[i])
+		}
+	}
+
+	if _, err := runNtfy(t, "reservations", "rm", "--delete-messages", "alerts"); err != nil {
+		t.Fatalf("rm failed: %v", err)
+	}
+	if len(fake.reservations) != 1 || fake.reservations[0].Topic != "builds" {
+		t.Errorf("reservations after rm are %+v", fake.reservations)
+	}
+	if len(fake.deleted) != 1 || fake.deleted[0] != "alerts" {
+		t.Errorf("messages deleted for %v, expected alerts", fake.deleted)
+	}
+
+	if _, err := runNtfy(t, "reservations", "rm", "missing"); err == nil {
+		t.Error("rm of a topic that is not reserved succeeded")
+	}
+	if _, err := runNtfy(t, "reservations", "rm", ""); err == nil {
+		t.Error("rm of an empty topic succeeded")
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/host"
+)
+
+const defaultServer = "https://ntfy.sh"
+
+// server is an ntfy server and the credentials to use with it: a token, or
+// a username and password.
+type server struct {
+	// Name is the name of the server in the settings, empty for the server
+	// set by `ntfy configure`.
+	Name     string
+	URL      string
+	Token    string
+	Username string
+	Password string
+	// MessageLimit is the message-size-limit of the server, in bytes, 0 for
+	// the default of ntfy.
+	MessageLimit int
+}
+
+// defaultServerSettings returns the server set by `ntfy configure`.
+func defaultServerSettings() *server {
+	s := &server{URL: defaultServer}
+	if url, _ := config.GetPluginSetting("ntfy", "server"); url != nil {
+		s.URL = url.(string)
+	}
+	if token, _ := config.GetPluginSetting("ntfy", "access-token"); token != nil {
+		s.Token = token.(string)
+	}
+	return s
+}
+
+func (s *server) messageLimit() int {
+	if s.MessageLimit > 0 {
+		return s.MessageLimit
+	}
+	return defaultMessageLimit
+}
+
+// String returns the name of the server, or its URL when it has none.
+func (s *server) String() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.URL
+}
+
+// newRequest returns a request to path on the server, with its credentials.
+func (s *server) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.URL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	switch {
+	case s.Token != "":
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	case s.Username != "":
+		req.SetBasicAuth(s.Username, s.Password)
+	}
+	return req, nil
+}
+
+// do sends req and decodes the JSON response into v, when v is not nil.
+func (s *server) do(req *http.Request, v interface{}) error {
+	client, err := host.HTTPClient("ntfy")
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errs.Network("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return errs.RemoteAPI("failed to decode the response of ntfy: %v", err)
+	}
+	return nil
+}
+
+// publish publishes msg with the JSON format and returns the message as
+// stored by the server.
+func (s *server) publish(ctx context.Context, msg *Message) (*Message, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the message: %v", err)
+	}
+
+	// JSON messages are published to the root of the server, the topic is
+	// part of the message
+	req, err := s.newRequest(ctx, "POST", "/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setDeliveryHeaders(req.Header, msg)
+
+	published := &Message{}
+	if err := s.do(req, published); err != nil {
+		return nil, err
+	}
+	return published, nil
+}
+
+// upload publishes msg with the file at path attached. The file is streamed
+// as the body of the request, the fields of the message are sent as headers.
+func (s *server) upload(ctx context.Context, msg *Message, path string) (*Message, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errs.NotFound("failed to open the attachment: %v", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the attachment: %v", err)
+	}
+	if info.IsDir() {
+		return nil, errs.Usage("%s is a directory, only files can be attached", path)
+	}
+
+	req, err := s.newRequest(ctx, "PUT", "/"+msg.Topic, file)
+	if err != nil {
+		return nil, err
+	}
+	// The file is streamed, the length lets the server refuse it upfront
+	// when it is too large
+	req.ContentLength = info.Size()
+
+	filename := msg.Filename
+	if filename == "" {
+		filename = filepath.Base(path)
+	}
+	if err := setHeaders(req.Header, msg, filename); err != nil {
+		return nil, err
+	}
+
+	published := &Message{}
+	err = s.do(req, published)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.HTTP == http.StatusRequestEntityTooLarge {
+		if limit := s.attachmentLimit(ctx); limit > 0 {
+			return nil, errs.RemoteAPI("%s is %s, over the attachment limit of %s of the server", filename, formatSize(info.Size()), formatSize(limit))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return published, nil
+}
+
+// setHeaders sets the fields of msg as headers of a publish request, those
+// with non-ASCII characters encoded as ntfy expects.
+func setHeaders(header http.Header, msg *Message, filename string) error {
+	set := func(key, value string) {
+		if value != "" {
+			header.Set(key, mime.BEncoding.Encode("utf-8", value))
+		}
+	}
+
+	set("Message", msg.Message)
+	set("Title", msg.Title)
+	set("Tags", strings.Join(msg.Tags, ","))
+	set("Click", msg.Click)
+	set("Icon", msg.Icon)
+	set("Email", msg.Email)
+	set("Filename", filename)
+	if msg.Priority != 0 {
+		set("Priority", strconv.Itoa(msg.Priority))
+	}
+	if msg.Markdown {
+		set("Markdown", "yes")
+	}
+	set("Delay", msg.Delay)
+	set("X-Sequence-ID", msg.SequenceID)
+	if len(msg.Actions) > 0 {
+		actions, err := json.Marshal(msg.Actions)
+		if err != nil {
+			return fmt.Errorf("failed to encode the actions: %v", err)
+		}
+		set("Actions", string(actions))
+	}
+	setDeliveryHeaders(header, msg)
+	return nil
+}
+
+// setDeliveryHeaders sets the options of msg that are only accepted as
+// headers.
+func setDeliveryHeaders(header http.Header, msg *Message) {
+	if msg.NoCache {
+		header.Set("Cache", "no")
+	}
+	if msg.NoFirebase {
+		header.Set("Firebase", "no")
+	}
+}
+
+// account is the response of /v1/account, describing the user, "*" for
+// anonymous users, and its limits, tokens and reserved topics.
+type account struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Limits   struct {
+		AttachmentFileSize int64 `json:"attachment_file_size"`
+		Reservations       int   `json:"reservations"`
+	} `json:"limits"`
+	Tokens       []*accessToken `json:"tokens"`
+	Reservations []*reservation `json:"reservations"`
+}
+
+// account returns the account of the credentials of s.
+func (s *server) account(ctx context.Context) (*account, error) {
+	req, err := s.newRequest(ctx, "GET", "/v1/account", nil)
+	if err != nil {
+		return nil, err
+	}
+	a := &account{}
+	if err := s.do(req, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// attachmentLimit returns the maximum size of an attachment, or 0 when the
+// server does not tell.
+func (s *server) attachmentLimit(ctx context.Context) int64 {
+	a, err := s.account(ctx)
+	if err != nil {
+		return 0
+	}
+	return a.Limits.AttachmentFileSize
+}
+
+// formatSize formats a number of bytes, e.g. 15.0 MB.
+func formatSize(size int64) string {
+	const unit = 1000
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "kMGTPE"[exp])
+}
+
+// apiError is an error response of ntfy.
+type apiError struct {
+	Code int `json:"code"`
+	HTTP int `json:"http"`
+	// Reason is the error given by the server, or the status of the
+	// response.
+	Reason string `json:"error"`
+}
+
+func (e *apiError) Error() string {
+	return e.Reason
+}
+
+// responseError returns the error of a non-OK response, with the reason
+// given by the server when there is one. The *apiError can be retrieved with
+// errors.As.
+func responseError(resp *http.Response) error {
+	body := &apiError{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(body); err == nil && body.Reason != "" {
+		body.Reason = fmt.Sprintf("%s (%s)", body.Reason, resp.Status)
+	} else {
+		body.Reason = resp.Status
+	}
+	body.HTTP = resp.StatusCode
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return errs.Auth("ntfy rejected the credentials: %w", body)
+	case http.StatusNotFound:
+		return errs.NotFound("ntfy returned not found: %w", body)
+	}
+	return errs.RemoteStatus(resp.StatusCode, "ntfy returned an error: %w", body)
+}
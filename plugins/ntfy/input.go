@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/host"
+)
+
+// defaultMessageLimit is the default message-size-limit of ntfy, in bytes.
+// Longer messages are refused or turned into attachments by the server.
+const defaultMessageLimit = 4096
+
+// checkFlags checks the flags of the message that do not depend on its
+// content, before stdin is read, and that stdin is read by one flag at most.
+func checkFlags(c *cli.Context) error {
+	if mode := c.String("oversize"); mode != "attach" && mode != "truncate" {
+		return errs.Usage("unknown --oversize %q, expected attach or truncate", mode)
+	}
+
+	var readers []string
+	for _, flag := range []string{"json", "message-file", "data"} {
+		if c.String(flag) == "-" {
+			readers = append(readers, "--"+flag)
+		}
+	}
+	if len(readers) > 1 {
+		return errs.Usage("only one of %s can read stdin", strings.Join(readers, " and "))
+	}
+	return nil
+}
+
+// readMessageInput returns the message of --message, of --message-file, or
+// of stdin when none is given and stdin is piped. ok is false when there is
+// no message.
+func readMessageInput(c *cli.Context) (message string, ok bool, err error) {
+	switch {
+	case c.IsSet("message") && c.IsSet("message-file"):
+		return "", false, errs.Usage("--message and --message-file cannot be used together")
+	case c.IsSet("message"):
+		return c.String("message"), true, nil
+	case c.IsSet("message-file"):
+		data, err := readInput(c.String("message-file"))
+		if err != nil {
+			return "", false, err
+		}
+		return strings.TrimRight(string(data), "\n"), true, nil
+	}
+
+	// Piped output is the message, unless stdin is used for something else
+	// or the notification is an attachment
+	if !host.StdinPiped() || c.String("json") == "-" || c.String("data") == "-" ||
+		c.IsSet("attach") || c.IsSet("attach-url") {
+		return "", false, nil
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read the message from stdin: %v", err)
+	}
+	message = strings.TrimRight(string(data), "\n")
+	return message, message != "", nil
+}
+
+// readInput returns the content of the file path, or of stdin when path is
+// "-".
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stdin: %v", err)
+		}
+		return data, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errs.NotFound("failed to read %s: %v", path, err)
+	}
+	return data, nil
+}
+
+// Template is the data available to the title and message templates of
+// `ntfy send --template`.
+type Template struct {
+	// Data is the JSON value given with --data.
+	Data     interface{}
+	Env      map[string]string
+	Hostname string
+	Time     time.Time
+}
+
+// templateData returns the data of the templates, with the JSON value of
+// --data: inline, read from a file prefixed with @, or from stdin with -.
+func templateData(c *cli.Context) (*Template, error) {
+	data := &Template{
+		Env:  make(map[string]string),
+		Time: time.Now(),
+	}
+	data.Hostname, _ = os.Hostname()
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			data.Env[k] = v
+		}
+	}
+
+	if !c.IsSet("data") {
+		return data, nil
+	}
+	raw := []byte(c.String("data"))
+	if s := c.String("data"); s == "-" || strings.HasPrefix(s, "@") {
+		var err error
+		if raw, err = readInput(strings.TrimPrefix(s, "@")); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(raw, &data.Data); err != nil {
+		return nil, errs.Usage("invalid JSON data: %v", err)
+	}
+	return data, nil
+}
+
+// renderMessage renders the title and message of msg as templates, when
+// --template or --data is given.
+func renderMessage(c *cli.Context, msg *Message) error {
+	if !c.Bool("template") && !c.IsSet("data") {
+		return nil
+	}
+	data, err := templateData(c)
+	if err != nil {
+		return err
+	}
+	for _, field := range []struct {
+		name  string
+		value *string
+	}{{"title", &msg.Title}, {"message", &msg.Message}} {
+		tmpl, err := template.New(field.name).Option("missingkey=error").Parse(*field.value)
+		if err != nil {
+			return errs.Usage("invalid %s template: %v", field.name, err)
+		}
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, data); err != nil {
+			return errs.Usage("failed to render the %s: %v", field.name, err)
+		}
+		*field.value = out.String()
+	}
+	return nil
+}
+
+// fitMessage makes the message of msg fit in the message limit of s. With
+// the attach mode, the whole message is attached as message.txt and the
+// message is its start, unless the notification already has an attachment:
+// the message is then truncated like with the truncate mode. The returned
+// attachment replaces attach, and cleanup removes the temporary file.
+func fitMessage(s *server, msg *Message, attach, mode string) (string, func(), error) {
+	cleanup := func() {}
+	limit := s.messageLimit()
+	if len(msg.Message) <= limit {
+		return attach, cleanup, nil
+	}
+
+	if mode == "attach" && attach == "" && msg.Attach == "" {
+		file, err := os.CreateTemp("", "support-ntfy-message-*.txt")
+		if err != nil {
+			return "", cleanup, fmt.Errorf("failed to create the attachment of the message: %v", err)
+		}
+		cleanup = func() { os.Remove(file.Name()) }
+		_, err = file.WriteString(msg.Message)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			cleanup()
+			return "", func() {}, fmt.Errorf("failed to write the attachment of the message: %v", err)
+		}
+
+		const note = "\n\n(the whole message is attached)"
+		msg.Message = truncateBytes(msg.Message, limit-len(note)) + note
+		if msg.Filename == "" {
+			msg.Filename = "message.txt"
+		}
+		return file.Name(), cleanup, nil
+	}
+
+	msg.Message = truncateBytes(msg.Message, limit)
+	return attach, cleanup, nil
+}
+
+// truncateBytes truncates s to at most n bytes, on a character boundary,
+// ending with an ellipsis when it is truncated.
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	const ellipsis = "…"
+	cut := n - len(ellipsis)
+	if cut < 0 {
+		cut = 0
+	}
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + ellipsis
+}
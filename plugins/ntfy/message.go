@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message is a message in the JSON format of ntfy, both as published and as
+// returned by the server.
+type Message struct {
+	ID       string    `json:"id,omitempty"`
+	Time     int64     `json:"time,omitempty"`
+	Expires  int64     `json:"expires,omitempty"`
+	Event    string    `json:"event,omitempty"`
+	Topic    string    `json:"topic"`
+	Message  string    `json:"message,omitempty"`
+	Title    string    `json:"title,omitempty"`
+	Tags     []string  `json:"tags,omitempty"`
+	Priority int       `json:"priority,omitempty"`
+	Click    string    `json:"click,omitempty"`
+	Icon     string    `json:"icon,omitempty"`
+	Markdown bool      `json:"markdown,omitempty"`
+	Email    string    `json:"email,omitempty"`
+	Actions  []*Action `json:"actions,omitempty"`
+	// Attach is the URL of an external attachment, and Filename its name.
+	Attach   string `json:"attach,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	// Attachment is set by the server on messages with an attachment.
+	Attachment *Attachment `json:"attachment,omitempty"`
+	// Delay schedules the message, see the --delay flag of send.
+	Delay string `json:"delay,omitempty"`
+	// SequenceID groups the versions of a message: publishing with the
+	// sequence ID of a message replaces it. The server uses the id of the
+	// message when it is not set.
+	SequenceID string `json:"sequence_id,omitempty"`
+
+	// NoCache and NoFirebase are only sent as headers, the JSON format
+	// has no field for them.
+	NoCache    bool `json:"-"`
+	NoFirebase bool `json:"-"`
+}
+
+// scheduled reports whether the message is delivered later than now.
+func (m *Message) scheduled() bool {
+	return m.Time > time.Now().Unix()
+}
+
+// Attachment is the attachment of a message, as returned by the server.
+type Attachment struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+	Size int64  `json:"size,omitempty"`
+	// Expires is the time the server deletes an uploaded attachment, unset
+	// for external attachments.
+	Expires int64  `json:"expires,omitempty"`
+	URL     string `json:"url"`
+}
+
+// Action is an action button of a message.
+type Action struct {
+	// Action is view, http or broadcast.
+	Action  string            `json:"action"`
+	Label   string            `json:"label"`
+	URL     string            `json:"url,omitempty"`
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	Intent  string            `json:"intent,omitempty"`
+	Extras  map[string]string `json:"extras,omitempty"`
+	Clear   bool              `json:"clear,omitempty"`
+}
+
+// priorities are the names of the priorities of ntfy.
+var priorities = map[string]int{
+	"min":     1,
+	"low":     2,
+	"default": 3,
+	"high":    4,
+	"max":     5,
+	"urgent":  5,
+}
+
+// parsePriority parses a priority given as a number from 1 to 5 or by name.
+func parsePriority(s string) (int, error) {
+	if p, ok := priorities[strings.ToLower(s)]; ok {
+		return p, nil
+	}
+	if p, err := strconv.Atoi(s); err == nil && p >= 1 && p <= 5 {
+		return p, nil
+	}
+	return 0, fmt.Errorf("invalid priority %q, expected 1-5, min, low, default, high, max or urgent", s)
+}
+
+// parseActions parses action buttons in the short format of ntfy, separated
+// by semicolons:
+//
+//	view, Open portal, https://example.com, clear=true; http, Restart, https://api.example.com/restart, method=PUT
+//
+// The third field is the URL of view and http actions. The other fields are
+// key=value pairs: clear, method, body, intent, url, headers.<name> and
+// extras.<name>. Values cannot contain commas or semicolons, use --json for
+// those.
+func parseActions(s string) ([]*Action, error) {
+	var actions []*Action
+	for _, definition := range strings.Split(s, ";") {
+		if strings.TrimSpace(definition) == "" {
+			continue
+		}
+
+		fields := strings.Split(definition, ",")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid action %q, expected <action>, <label>, ...", strings.TrimSpace(definition))
+		}
+
+		action := &Action{Action: strings.ToLower(fields[0]), Label: fields[1]}
+		switch action.Action {
+		case "view", "http", "broadcast":
+		default:
+			return nil, fmt.Errorf("invalid action %q, expected view, http or broadcast", fields[0])
+		}
+
+		for _, field := range fields[2:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok || strings.Contains(key, "/") {
+				// A URL, which may contain an equal sign in its query
+				action.URL = field
+				continue
+			}
+			if err := action.set(strings.TrimSpace(key), strings.TrimSpace(value)); err != nil {
+				return nil, err
+			}
+		}
+
+		if action.Action != "broadcast" && action.URL == "" {
+			return nil, fmt.Errorf("%s action %q requires a URL", action.Action, action.Label)
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+func (a *Action) set(key, value string) error {
+	switch {
+	case key == "clear":
+		clear, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid clear value %q in action %q", value, a.Label)
+		}
+		a.Clear = clear
+	case key == "url":
+		a.URL = value
+	case key == "method":
+		a.Method = value
+	case key == "body":
+		a.Body = value
+	case key == "intent":
+		a.Intent = value
+	case strings.HasPrefix(key, "headers."):
+		if a.Headers == nil {
+			a.Headers = make(map[string]string)
+		}
+		a.Headers[strings.TrimPrefix(key, "headers.")] = value
+	case strings.HasPrefix(key, "extras."):
+		if a.Extras == nil {
+			a.Extras = make(map[string]string)
+		}
+		a.Extras[strings.TrimPrefix(key, "extras.")] = value
+	default:
+		return fmt.Errorf("unknown key %q in action %q", key, a.Label)
+	}
+	return nil
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/guard"
+)
+
+// maxSentMessages is the number of sent messages remembered, the oldest are
+// forgotten first.
+const maxSentMessages = 200
+
+// sentMessage is a message sent with support, recorded so that it can be
+// updated or deleted later.
+type sentMessage struct {
+	// Name is the name given with --name, unique among the messages.
+	Name string `json:"name,omitempty"`
+	// Key is the idempotency key given with --idempotency-key, if any.
+	Key        string `json:"key,omitempty"`
+	ID         string `json:"id"`
+	SequenceID string `json:"sequence_id,omitempty"`
+	Topic      string `json:"topic"`
+	Server     string `json:"server"`
+	// ServerName is the name of the server in the settings, if any.
+	ServerName string `json:"server_name,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Message    string `json:"message,omitempty"`
+	// Time is the delivery time of the message, in the future when it is
+	// scheduled.
+	Time    int64 `json:"time"`
+	Deleted bool  `json:"deleted,omitempty"`
+}
+
+// sequence returns the sequence ID of the message, its id unless the server
+// returned one.
+func (m *sentMessage) sequence() string {
+	if m.SequenceID != "" {
+		return m.SequenceID
+	}
+	return m.ID
+}
+
+func (m *sentMessage) status() string {
+	switch {
+	case m.Deleted:
+		return "deleted"
+	case m.Time > time.Now().Unix():
+		return "scheduled"
+	}
+	return "delivered"
+}
+
+func (m *sentMessage) update(published *Message) {
+	m.ID = published.ID
+	m.SequenceID = published.SequenceID
+	m.Topic = published.Topic
+	m.Title = published.Title
+	m.Message = published.Message
+	m.Time = published.Time
+	m.Deleted = false
+}
+
+func sentMessagesPath() string {
+	return filepath.Join(config.SupportDir(), "ntfy", "messages.json")
+}
+
+func readSentMessages() ([]*sentMessage, error) {
+	data, err := os.ReadFile(sentMessagesPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the sent messages: %v", err)
+	}
+
+	var messages []*sentMessage
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return nil, fmt.Errorf("failed to read the sent messages: %v", err)
+	}
+	return messages, nil
+}
+
+func writeSentMessages(messages []*sentMessage) error {
+	if len(messages) > maxSentMessages {
+		messages = messages[len(messages)-maxSentMessages:]
+	}
+
+	data, err := json.MarshalIndent(messages, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(sentMessagesPath(), append(data, '\n'), 0700)
+}
+
+// editSentMessages replaces the sent messages with what edit returns for
+// them, with the lock of the sent messages held so that processes sending
+// at the same time do not lose each other's messages.
+func editSentMessages(ctx context.Context, edit func([]*sentMessage) []*sentMessage) error {
+	unlock, err := lockFile(ctx, sentMessagesPath()+".lock")
+	if err != nil {
+		return fmt.Errorf("failed to lock the sent messages: %w", err)
+	}
+	defer unlock()
+
+	messages, err := readSentMessages()
+	if err != nil {
+		return err
+	}
+	return writeSentMessages(edit(messages))
+}
+
+// recordMessage records the message published to s, under name and with the
+// idempotency key key when they are set. A message already recorded under
+// name loses it.
+func recordMessage(ctx context.Context, name, key string, s *server, published *Message) error {
+	return editSentMessages(ctx, func(messages []*sentMessage) []*sentMessage {
+		if name != "" {
+			for _, m := range messages {
+				if m.Name == name {
+					m.Name = ""
+				}
+			}
+		}
+
+		m := &sentMessage{Name: name, Key: key, Server: s.URL, ServerName: s.Name}
+		m.update(published)
+		return append(messages, m)
+	})
+}
+
+// changeSentMessage applies change to the recorded message with the id id.
+func changeSentMessage(ctx context.Context, id string, change func(*sentMessage)) error {
+	return editSentMessages(ctx, func(messages []*sentMessage) []*sentMessage {
+		for _, m := range messages {
+			if m.ID == id {
+				change(m)
+			}
+		}
+		return messages
+	})
+}
+
+// findSentMessage returns the message named ref, or with the id or sequence
+// ID ref.
+func findSentMessage(messages []*sentMessage, ref string) (*sentMessage, error) {
+	for _, m := range messages {
+		if m.Name == ref {
+			return m, nil
+		}
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].ID == ref || messages[i].SequenceID == ref {
+			return messages[i], nil
+		}
+	}
+	return nil, errs.NotFound("no message named %s was sent, see `support ntfy messages list`", ref)
+}
+
+// sentWithKey returns the message sent with the idempotency key key, or nil.
+func sentWithKey(key string) (*sentMessage, error) {
+	messages, err := readSentMessages()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range messages {
+		if m.Key == key {
+			return m, nil
+		}
+	}
+	return nil, nil
+}
+
+// messageNames returns the names of the sent messages, for completion.
+func messageNames() []string {
+	messages, _ := readSentMessages()
+	var names []string
+	for _, m := range messages {
+		if m.Name != "" {
+			names = append(names, m.Name)
+		}
+	}
+	return names
+}
+
+// serverFor returns the server m was sent to, with its credentials when it
+// is still configured.
+func serverFor(m *sentMessage) *server {
+	return knownServer(m.ServerName, m.Server)
+}
+
+// knownServer returns the server at url, with the credentials of the server
+// called name, or of the server set by `ntfy configure`, when it still has
+// this url.
+func knownServer(name, url string) *server {
+	if name != "" {
+		if s, err := namedServer(name); err == nil && s.URL == url {
+			return s
+		}
+	}
+	if s := defaultServerSettings(); s.URL == url {
+		return s
+	}
+	return &server{URL: url}
+}
+
+func ListMessages(c *cli.Context) error {
+	messages, err := readSentMessages()
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("scheduled") {
+		var scheduled []*sentMessage
+		for _, m := range messages {
+			if m.status() == "scheduled" {
+				scheduled = append(scheduled, m)
+			}
+		}
+		messages = scheduled
+	}
+
+	switch c.String("format") {
+	case "json":
+		if messages == nil {
+			messages = []*sentMessage{}
+		}
+		return printJSON(messages)
+	case "text":
+	default:
+		return errs.Usage("unknown format %q, expected text or json", c.String("format"))
+	}
+
+	if len(messages) == 0 {
+		fmt.Println("No messages")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tID\tTOPIC\tDELIVERY\tSTATUS\tMESSAGE")
+	for _, m := range messages {
+		text := m.Message
+		if m.Title != "" {
+			text = m.Title + ": " + text
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", dash(m.Name), m.ID, m.Topic, time.Unix(m.Time, 0).Format("2006-01-02 15:04"), m.status(), truncate(text, 40))
+	}
+	return w.Flush()
+}
+
+func UpdateMessage(c *cli.Context) error {
+	if !c.Args().Present() {
+		return errs.Usage("expected the name or id of a message")
+	}
+	if c.Args().Len() > 1 {
+		return errs.Usage("unexpected arguments %q, flags must come before the message name", c.Args().Tail())
+	}
+	format := c.String("format")
+	if format != "text" && format != "json" {
+		return errs.Usage("unknown format %q, expected text or json", format)
+	}
+
+	messages, err := readSentMessages()
+	if err != nil {
+		return err
+	}
+	sent, err := findSentMessage(messages, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	msg, err := messageFromFlags(c)
+	if err != nil {
+		return err
+	}
+	msg.Topic = sent.Topic
+	msg.SequenceID = sent.sequence()
+	if err := validateMessage(c, msg); err != nil {
+		return err
+	}
+
+	s := serverFor(sent)
+	attach, cleanup, err := fitMessage(s, msg, c.String("attach"), c.String("oversize"))
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	published, err := s.send(c.Context, msg, attach)
+	if err != nil {
+		return err
+	}
+	if published.SequenceID != msg.SequenceID {
+		fmt.Fprintln(os.Stderr, "Warning: the server does not support updating messages, the update was sent as a new message")
+	}
+
+	err = changeSentMessage(c.Context, sent.ID, func(m *sentMessage) { m.update(published) })
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error recording the message:", err)
+	}
+	return printPublished(published, format)
+}
+
+func DeleteMessage(c *cli.Context) error {
+	if !c.Args().Present() {
+		return errs.Usage("expected the name or id of a message")
+	}
+	if c.Args().Len() > 1 {
+		return errs.Usage("unexpected arguments %q, flags must come before the message name", c.Args().Tail())
+	}
+
+	messages, err := readSentMessages()
+	if err != nil {
+		return err
+	}
+	sent, err := findSentMessage(messages, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	target := fmt.Sprintf("%s (%s on %s)", sent.ID, sent.status(), sent.Topic)
+	if guard.DryRun(c) {
+		fmt.Println("Would delete message:", target)
+		return nil
+	}
+	if err := guard.Confirm(c, "delete", []string{target}); err != nil {
+		return err
+	}
+
+	s := serverFor(sent)
+	req, err := s.newRequest(c.Context, "DELETE", "/"+sent.Topic+"/"+sent.sequence(), nil)
+	if err != nil {
+		return err
+	}
+	err = s.do(req, nil)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && (apiErr.HTTP == http.StatusNotFound || apiErr.HTTP == http.StatusMethodNotAllowed) {
+		return errs.RemoteAPI("the server does not support deleting messages: %w", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = changeSentMessage(c.Context, sent.ID, func(m *sentMessage) { m.Deleted = true })
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error recording the message:", err)
+	}
+	fmt.Println("Deleted message", sent.ID)
+	return nil
+}
+
+func truncate(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if len([]rune(s)) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-3]) + "..."
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -2,40 +2,453 @@ package main
 
 import (
 	"fmt"
-	"net/http"
 	"strings"
 
 	"github.com/urfave/cli/v2"
 	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/guard"
+	"go.codycody31.dev/support/plugins"
 )
 
 func Name() string {
 	return "ntfy"
 }
 
+func Manifest() plugins.Manifest {
+	return plugins.Manifest{
+		Name:        "ntfy",
+		Version:     "0.11.0",
+		Description: "Send and receive notifications via ntfy.sh or a self-hosted ntfy server",
+		Settings: []plugins.Setting{
+			{Key: "server", Type: "string", Default: "https://ntfy.sh", Description: "URL of the ntfy server, set by `ntfy configure`"},
+			{Key: "access-token", Type: "string", Description: "Access token sent to the server, set by `ntfy configure`", Secret: true},
+			{Key: "servers", Type: "map", Description: "Named servers with their url and token, or username and password, set by `ntfy servers add`", Secret: true},
+			{Key: "default-server", Type: "string", Description: "Name of the server used for topics not mapped to a server, instead of `server`"},
+			{Key: "topics", Type: "map", Description: "Name of the server of each topic"},
+		},
+	}
+}
+
 func SetupCommands() []*cli.Command {
 	return []*cli.Command{
 		{
 			Name:  "ntfy",
-			Usage: "Send notifications via ntfy.sh",
+			Usage: "Send and receive notifications via ntfy.sh",
 			Subcommands: []*cli.Command{
 				{
 					Name:   "send",
 					Usage:  "Send a notification",
 					Action: NtfySend,
+					Flags: append([]cli.Flag{
+						&cli.StringFlag{
+							Name:    "topic",
+							Aliases: []string{"t"},
+							Usage:   "Notification topic",
+						},
+						&cli.StringFlag{
+							Name:  "name",
+							Usage: "Remember the message under this name, to update or delete it later",
+						},
+						&cli.StringFlag{
+							Name:    "server",
+							Aliases: []string{"s"},
+							Usage:   "Name of the server, instead of the server of the topic or the default one",
+						},
+						&cli.StringFlag{
+							Name:  "idempotency-key",
+							Usage: "Key identifying the notification, sent only once whatever the number of attempts",
+						},
+						&cli.BoolFlag{
+							Name:  "outbox",
+							Usage: "Queue the notification in the outbox when the server cannot be reached, to send it later",
+							Value: true,
+						},
+					}, messageFlags()...),
+				},
+				{
+					Name:      "subscribe",
+					Usage:     "Print the messages published to topics, and optionally run a command for each",
+					ArgsUsage: "<topic...>",
+					Action:    NtfySubscribe,
 					Flags: []cli.Flag{
 						&cli.StringFlag{
-							Name:     "topic",
-							Aliases:  []string{"t"},
-							Usage:    "Notification topic",
-							Required: true,
+							Name:    "server",
+							Aliases: []string{"s"},
+							Usage:   "Name of the server, instead of the server of the topic or the default one",
 						},
 						&cli.StringFlag{
-							Name:     "message",
-							Aliases:  []string{"m"},
-							Usage:    "Notification message",
-							Required: true,
+							Name:  "since",
+							Usage: "Also return the cached messages since a duration (10m), a Unix time, a message id, or all",
+						},
+						&cli.BoolFlag{
+							Name:  "poll",
+							Usage: "Return the cached messages and exit instead of waiting for new ones",
+						},
+						&cli.StringFlag{
+							Name:    "priority",
+							Aliases: []string{"p"},
+							Usage:   "Only messages with one of these priorities, comma separated",
+						},
+						&cli.StringSliceFlag{
+							Name:  "tags",
+							Usage: "Only messages with all of these tags, comma separated",
+						},
+						&cli.StringFlag{
+							Name:    "message",
+							Aliases: []string{"m"},
+							Usage:   "Only messages with exactly this message",
+						},
+						&cli.StringFlag{
+							Name:  "title",
+							Usage: "Only messages with exactly this title",
+						},
+						&cli.StringFlag{
+							Name:    "format",
+							Aliases: []string{"f"},
+							Usage:   "Output format: text or json, a message object per line",
+							Value:   "text",
+						},
+						&cli.StringFlag{
+							Name:  "command",
+							Usage: "support command run for each message, e.g. \"caprover list\", with the message in NTFY_* environment variables",
+						},
+						&cli.StringFlag{
+							Name:  "routine",
+							Usage: "Routine run for each message, with the message in NTFY_* environment variables",
+						},
+					},
+				},
+				{
+					Name:      "run",
+					Usage:     "Run a command and send a notification when it completes",
+					ArgsUsage: "-- <command> [args...]",
+					Action:    NtfyRun,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:    "topic",
+							Aliases: []string{"t"},
+							Usage:   "Notification topic",
+						},
+						&cli.StringFlag{
+							Name:    "server",
+							Aliases: []string{"s"},
+							Usage:   "Name of the server, instead of the server of the topic or the default one",
+						},
+						&cli.StringFlag{
+							Name:  "idempotency-key",
+							Usage: "Key identifying the notification, sent only once whatever the number of attempts",
+						},
+						&cli.BoolFlag{
+							Name:  "outbox",
+							Usage: "Queue the notification in the outbox when the server cannot be reached, to send it later",
+							Value: true,
+						},
+						&cli.IntFlag{
+							Name:    "lines",
+							Aliases: []string{"n"},
+							Usage:   "Number of lines at the end of the output available to the message as .Output",
+							Value:   10,
+						},
+						&cli.StringFlag{
+							Name:  "title",
+							Usage: "Go template of the title, see the fields of Run in the README",
+							Value: defaultRunTitle,
+						},
+						&cli.StringFlag{
+							Name:    "message",
+							Aliases: []string{"m"},
+							Usage:   "Go template of the message",
+							Value:   defaultRunMessage,
+						},
+						&cli.StringFlag{
+							Name:  "success-priority",
+							Usage: "Priority of the notification when the command succeeds",
+							Value: "default",
+						},
+						&cli.StringFlag{
+							Name:  "failure-priority",
+							Usage: "Priority of the notification when the command fails",
+							Value: "high",
+						},
+						&cli.StringSliceFlag{
+							Name:  "success-tags",
+							Usage: "Tags of the notification when the command succeeds",
+							Value: cli.NewStringSlice("white_check_mark"),
+						},
+						&cli.StringSliceFlag{
+							Name:  "failure-tags",
+							Usage: "Tags of the notification when the command fails",
+							Value: cli.NewStringSlice("x"),
+						},
+						&cli.StringFlag{
+							Name:  "attach-log",
+							Usage: "Attach the whole output: never, failure or always",
+							Value: "never",
+						},
+					},
+				},
+				{
+					Name:  "messages",
+					Usage: "Manage the messages sent with support",
+					Subcommands: []*cli.Command{
+						{
+							Name:   "list",
+							Usage:  "List the messages sent with support",
+							Action: ListMessages,
+							Flags: []cli.Flag{
+								&cli.BoolFlag{
+									Name:  "scheduled",
+									Usage: "Only the messages not delivered yet",
+								},
+								&cli.StringFlag{
+									Name:    "format",
+									Aliases: []string{"f"},
+									Usage:   "Output format: text or json",
+									Value:   "text",
+								},
+							},
+						},
+						{
+							Name:      "update",
+							Usage:     "Replace a message sent before, on the clients supporting it",
+							ArgsUsage: "<name|id>",
+							Action:    UpdateMessage,
+							Flags:     messageFlags(),
+						},
+						guard.Destructive(&cli.Command{
+							Name:      "delete",
+							Usage:     "Delete a message sent before, or cancel it if it is scheduled",
+							ArgsUsage: "<name|id>",
+							Action:    DeleteMessage,
+						}),
+					},
+				},
+				{
+					Name:  "outbox",
+					Usage: "Manage the notifications queued while their server could not be reached",
+					Subcommands: []*cli.Command{
+						{
+							Name:   "list",
+							Usage:  "List the queued notifications",
+							Action: ListOutbox,
+							Flags: []cli.Flag{
+								&cli.StringFlag{
+									Name:    "format",
+									Aliases: []string{"f"},
+									Usage:   "Output format: text or json",
+									Value:   "text",
+								},
+							},
+						},
+						{
+							Name:      "flush",
+							Usage:     "Send the queued notifications, all of them unless ids are given",
+							ArgsUsage: "[id...]",
+							Action:    FlushOutbox,
+							Flags: []cli.Flag{
+								&cli.BoolFlag{
+									Name:  "due",
+									Usage: "Only the notifications whose retry delay is over, e.g. from a scheduler job",
+								},
+							},
+						},
+						guard.Destructive(&cli.Command{
+							Name:      "drop",
+							Usage:     "Remove queued notifications without sending them",
+							ArgsUsage: "[id...]",
+							Action:    DropOutbox,
+							Flags: []cli.Flag{
+								&cli.BoolFlag{
+									Name:  "all",
+									Usage: "Drop all the queued notifications",
+								},
+							},
+						}),
+					},
+				},
+				{
+					Name:  "servers",
+					Usage: "Manage the named ntfy servers",
+					Subcommands: []*cli.Command{
+						{
+							Name:      "add",
+							Usage:     "Add or replace a named server",
+							ArgsUsage: "<name>",
+							Action:    AddServer,
+							Flags: []cli.Flag{
+								&cli.StringFlag{
+									Name:     "url",
+									Aliases:  []string{"u"},
+									Usage:    "URL of the server, e.g. https://ntfy.sh",
+									Required: true,
+								},
+								&cli.StringFlag{
+									Name:    "token",
+									Aliases: []string{"a"},
+									Usage:   "Access token",
+								},
+								&cli.StringFlag{
+									Name:  "username",
+									Usage: "Username for basic authentication, the password is prompted for unless --password is given",
+								},
+								&cli.StringFlag{
+									Name:  "password",
+									Usage: "Password for basic authentication",
+								},
+								&cli.StringSliceFlag{
+									Name:  "topic",
+									Usage: "Topics sent to and read from this server by default, comma separated or repeated",
+								},
+								&cli.BoolFlag{
+									Name:  "default",
+									Usage: "Use this server for the topics not mapped to a server",
+								},
+								&cli.IntFlag{
+									Name:  "message-limit",
+									Usage: "message-size-limit of the server in bytes, when it is not the default of 4096",
+								},
+							},
+						},
+						{
+							Name:      "rm",
+							Usage:     "Remove a named server",
+							ArgsUsage: "<name>",
+							Action:    RemoveServer,
+						},
+						{
+							Name:   "list",
+							Usage:  "List the servers and the topics mapped to them",
+							Action: ListServers,
+						},
+						{
+							Name:      "test",
+							Usage:     "Check the credentials of servers, the default one when none is given",
+							ArgsUsage: "[name...]",
+							Action:    TestServers,
+						},
+					},
+				},
+				{
+					Name:  "tokens",
+					Usage: "Manage the access tokens of the account of a server",
+					Subcommands: []*cli.Command{
+						{
+							Name:   "create",
+							Usage:  "Create an access token",
+							Action: CreateToken,
+							Flags: []cli.Flag{
+								&cli.StringFlag{
+									Name:    "server",
+									Aliases: []string{"s"},
+									Usage:   "Name of the server, instead of the default one",
+								},
+								&cli.StringFlag{
+									Name:  "label",
+									Usage: "Label of the token, e.g. the host using it",
+								},
+								&cli.StringFlag{
+									Name:  "expires",
+									Usage: "Expire the token after a duration, e.g. 12h or 30d, or never",
+									Value: "never",
+								},
+								&cli.StringFlag{
+									Name:    "format",
+									Aliases: []string{"f"},
+									Usage:   "Output format: text or json",
+									Value:   "text",
+								},
+							},
+						},
+						{
+							Name:   "list",
+							Usage:  "List the access tokens",
+							Action: ListTokens,
+							Flags: []cli.Flag{
+								&cli.StringFlag{
+									Name:    "server",
+									Aliases: []string{"s"},
+									Usage:   "Name of the server, instead of the default one",
+								},
+								&cli.StringFlag{
+									Name:    "format",
+									Aliases: []string{"f"},
+									Usage:   "Output format: text or json",
+									Value:   "text",
+								},
+							},
 						},
+						guard.Destructive(&cli.Command{
+							Name:      "revoke",
+							Usage:     "Revoke access tokens",
+							ArgsUsage: "<token...>",
+							Action:    RevokeTokens,
+							Flags: []cli.Flag{
+								&cli.StringFlag{
+									Name:    "server",
+									Aliases: []string{"s"},
+									Usage:   "Name of the server, instead of the default one",
+								},
+							},
+						}),
+					},
+				},
+				{
+					Name:  "reservations",
+					Usage: "Manage the topics reserved by the account of a server",
+					Subcommands: []*cli.Command{
+						{
+							Name:      "add",
+							Usage:     "Reserve a topic, with the access of the other users to it",
+							ArgsUsage: "<topic>",
+							Action:    AddReservation,
+							Flags: []cli.Flag{
+								&cli.StringFlag{
+									Name:    "server",
+									Aliases: []string{"s"},
+									Usage:   "Name of the server, instead of the server of the topic or the default one",
+								},
+								&cli.StringFlag{
+									Name:  "everyone",
+									Usage: "Access of the other users: deny-all, read-only, write-only or read-write",
+									Value: "deny-all",
+								},
+							},
+						},
+						{
+							Name:   "list",
+							Usage:  "List the reserved topics",
+							Action: ListReservations,
+							Flags: []cli.Flag{
+								&cli.StringFlag{
+									Name:    "server",
+									Aliases: []string{"s"},
+									Usage:   "Name of the server, instead of the default one",
+								},
+								&cli.StringFlag{
+									Name:    "format",
+									Aliases: []string{"f"},
+									Usage:   "Output format: text or json",
+									Value:   "text",
+								},
+							},
+						},
+						guard.Destructive(&cli.Command{
+							Name:      "rm",
+							Usage:     "Release reserved topics",
+							ArgsUsage: "<topic...>",
+							Action:    RemoveReservations,
+							Flags: []cli.Flag{
+								&cli.StringFlag{
+									Name:    "server",
+									Aliases: []string{"s"},
+									Usage:   "Name of the server, instead of the server of the topic or the default one",
+								},
+								&cli.BoolFlag{
+									Name:  "delete-messages",
+									Usage: "Also delete the messages of the topics cached by the server",
+								},
+							},
+						}),
 					},
 				},
 				{
@@ -61,43 +474,106 @@ func SetupCommands() []*cli.Command {
 	}
 }
 
-func NtfySend(c *cli.Context) error {
-	topic := c.String("topic")
-	message := c.String("message")
-	server, _ := config.GetPluginSetting("ntfy", "server")
-
-	if topic == "" || message == "" {
-		return fmt.Errorf("both topic and message are required")
-	}
-
-	serverURL := "https://ntfy.sh"
-	if server != nil {
-		serverURL = server.(string)
-	}
-
-	url := fmt.Sprintf("%s/%s", serverURL, topic)
-	req, err := http.NewRequest("POST", url, strings.NewReader(message))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
-	}
-	req.Header.Set("Title", "Notification")
-	if token, _ := config.GetPluginSetting("ntfy", "access-token"); token != nil {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-	}
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("received non-OK response: %s", resp.Status)
+// messageFlags returns the flags describing the content and delivery of a
+// message, shared by send and messages update.
+func messageFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "message",
+			Aliases: []string{"m"},
+			Usage:   "Notification message",
+		},
+		&cli.StringFlag{
+			Name:  "message-file",
+			Usage: "Read the message from this file, or from stdin with -. Without a message, piped input is the message",
+		},
+		&cli.StringFlag{
+			Name:  "oversize",
+			Usage: "When the message exceeds the size limit of the server: attach, to attach it as message.txt, or truncate",
+			Value: "attach",
+		},
+		&cli.BoolFlag{
+			Name:  "template",
+			Usage: "Render the title and message as Go templates, with .Env, .Hostname, .Time and .Data",
+		},
+		&cli.StringFlag{
+			Name:  "data",
+			Usage: "JSON value available to the templates as .Data, inline, @file or - for stdin, implies --template",
+		},
+		&cli.StringFlag{
+			Name:  "title",
+			Usage: "Notification title",
+		},
+		&cli.StringFlag{
+			Name:    "priority",
+			Aliases: []string{"p"},
+			Usage:   "Priority, 1-5 or min, low, default, high, urgent",
+		},
+		&cli.StringSliceFlag{
+			Name:  "tags",
+			Usage: "Tags, comma separated, those matching an emoji short code are shown as emojis",
+		},
+		&cli.StringFlag{
+			Name:  "click",
+			Usage: "URL opened when the notification is clicked",
+		},
+		&cli.StringFlag{
+			Name:  "icon",
+			Usage: "URL of the icon of the notification",
+		},
+		&cli.BoolFlag{
+			Name:  "markdown",
+			Usage: "Render the message as Markdown",
+		},
+		&cli.StringFlag{
+			Name:  "email",
+			Usage: "Also forward the notification to this email address",
+		},
+		&cli.StringFlag{
+			Name:  "actions",
+			Usage: "Action buttons separated by semicolons, e.g. \"view, Open, https://example.com; http, Restart, https://example.com/restart, method=PUT\"",
+		},
+		&cli.StringFlag{
+			Name:  "attach",
+			Usage: "Upload this file as the attachment of the notification, the message is then optional",
+		},
+		&cli.StringFlag{
+			Name:  "attach-url",
+			Usage: "URL of an external file to attach, the message is then optional",
+		},
+		&cli.StringFlag{
+			Name:  "filename",
+			Usage: "Name of the attachment, defaults to the name of the file",
+		},
+		&cli.StringFlag{
+			Name:  "delay",
+			Usage: "Deliver the notification later, after a duration (30m), at a Unix time or a time understood by ntfy (tomorrow, 10am)",
+		},
+		&cli.StringFlag{
+			Name:  "at",
+			Usage: "Deliver the notification at this local time: 15:04, \"2006-01-02 15:04\" or RFC 3339",
+		},
+		&cli.BoolFlag{
+			Name:  "cache",
+			Usage: "Cache the message on the server, for clients connecting later",
+			Value: true,
+		},
+		&cli.BoolFlag{
+			Name:  "firebase",
+			Usage: "Forward the message to the Android app through Firebase",
+			Value: true,
+		},
+		&cli.StringFlag{
+			Name:    "format",
+			Aliases: []string{"f"},
+			Usage:   "Output format: text or json, the message as stored by the server",
+			Value:   "text",
+		},
+		&cli.StringFlag{
+			Name:  "json",
+			Usage: "Full message object in the JSON format of ntfy, or - to read it from stdin, overridden by the other flags",
+		},
 	}
-
-	fmt.Println("Notification sent successfully!")
-	return nil
 }
 
 func ConfigureNtfy(c *cli.Context) error {
@@ -122,3 +598,25 @@ func ConfigureNtfy(c *cli.Context) error {
 	fmt.Printf("Ntfy server set to %s\n", url)
 	return nil
 }
+
+// Complete completes the --topic flag of send and the topics of subscribe
+// with the topics used before, the messages of update and delete with their
+// names, servers with their names, queued notifications with their ids and
+// reserved topics with the topics used before.
+func Complete(command []string, flag, prefix string) []string {
+	switch {
+	case len(command) == 2 && (command[1] == "send" || command[1] == "run") && flag == "topic",
+		len(command) == 2 && command[1] == "subscribe" && flag == "":
+		return topicHistory()
+	case len(command) == 3 && command[1] == "messages" && flag == "":
+		return messageNames()
+	case flag == "server",
+		len(command) == 3 && command[1] == "servers" && command[2] != "add" && flag == "":
+		return serverNames()
+	case len(command) == 3 && command[1] == "outbox" && command[2] != "list" && flag == "":
+		return outboxIDs()
+	case len(command) == 3 && command[1] == "reservations" && command[2] != "list" && flag == "":
+		return topicHistory()
+	}
+	return nil
+}
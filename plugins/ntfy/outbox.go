@@ -0,0 +1,660 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/guard"
+)
+
+// lockPollInterval is how often the lock of the outbox is tried while
+// another process holds it.
+const lockPollInterval = 100 * time.Millisecond
+
+var (
+	// deliverMu serializes the deliveries of the process, such as those of
+	// the ntfy channels of `support notify` sent at once.
+	deliverMu sync.Mutex
+	// flushed is set once the process sent the due messages of the outbox.
+	flushed bool
+)
+
+const (
+	// The delay before retrying a queued message doubles with each failed
+	// attempt, from minRetryDelay up to maxRetryDelay.
+	minRetryDelay = 30 * time.Second
+	maxRetryDelay = time.Hour
+)
+
+// outboxEntry is a message that could not be sent because the server was
+// unreachable, kept in the outbox to be sent later. Each entry is stored in
+// ~/.support/ntfy/outbox/<id>.json, next to the copy of its attachment.
+type outboxEntry struct {
+	// ID is derived from the idempotency key. The sequence ID the message
+	// was first sent with is kept in Message, so that the clients of
+	// servers supporting sequence IDs show a message sent twice only once.
+	ID string `json:"id"`
+	// Key is the idempotency key given with --idempotency-key, or a hash of
+	// the server and the message.
+	Key string `json:"key"`
+	// Explicit is set when the key was given, the message is then never
+	// sent again once it was sent.
+	Explicit   bool     `json:"explicit,omitempty"`
+	Name       string   `json:"name,omitempty"`
+	Server     string   `json:"server"`
+	ServerName string   `json:"server_name,omitempty"`
+	Message    *Message `json:"message"`
+	NoCache    bool     `json:"no_cache,omitempty"`
+	NoFirebase bool     `json:"no_firebase,omitempty"`
+	// Attach is the copy of the attachment in the outbox, if any.
+	Attach      string `json:"attach,omitempty"`
+	Queued      int64  `json:"queued"`
+	Attempts    int    `json:"attempts"`
+	NextAttempt int64  `json:"next_attempt"`
+	LastError   string `json:"last_error,omitempty"`
+}
+
+func outboxDir() string {
+	return filepath.Join(config.SupportDir(), "ntfy", "outbox")
+}
+
+func (e *outboxEntry) path() string {
+	return filepath.Join(outboxDir(), e.ID+".json")
+}
+
+func (e *outboxEntry) due() bool {
+	return e.NextAttempt <= time.Now().Unix()
+}
+
+// message returns the message to send, with the options that are not part
+// of its JSON.
+func (e *outboxEntry) message() *Message {
+	msg := *e.Message
+	msg.NoCache = e.NoCache
+	msg.NoFirebase = e.NoFirebase
+	return &msg
+}
+
+// outboxKey returns key, or when it is empty a hash of the server, the
+// message and the attachment, so that the same message is only queued once.
+func outboxKey(key string, s *server, msg *Message, attach string) string {
+	if key != "" {
+		return key
+	}
+	data, _ := json.Marshal(msg)
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%s\n%t %t\n%s", s.URL, data, msg.NoCache, msg.NoFirebase, attach)))
+	return hex.EncodeToString(sum[:])
+}
+
+func outboxID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// sequenceID returns the sequence ID of a message sent with key: derived
+// from the key when it was given with --idempotency-key, random otherwise so
+// that the same message sent again later is a new message on the clients.
+func sequenceID(key string, explicit bool) string {
+	if explicit {
+		return outboxID(key)
+	}
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// readOutbox returns the queued messages, the oldest first.
+func readOutbox() ([]*outboxEntry, error) {
+	paths, err := filepath.Glob(filepath.Join(outboxDir(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*outboxEntry
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			// Sent or dropped meanwhile
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the outbox: %v", err)
+		}
+		e := &outboxEntry{}
+		if err := json.Unmarshal(data, e); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		entries = append(entries, e)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Queued < entries[j].Queued
+	})
+	return entries, nil
+}
+
+// outboxIDs returns the ids of the queued messages, for completion.
+func outboxIDs() []string {
+	entries, _ := readOutbox()
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = e.ID
+	}
+	return ids
+}
+
+// queuedEntry returns the entry with the id or key ref, or nil.
+func queuedEntry(ref string) (*outboxEntry, error) {
+	data, err := os.ReadFile(filepath.Join(outboxDir(), outboxID(ref)+".json"))
+	if os.IsNotExist(err) {
+		data, err = os.ReadFile(filepath.Join(outboxDir(), filepath.Base(ref)+".json"))
+	}
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the outbox: %v", err)
+	}
+	e := &outboxEntry{}
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, fmt.Errorf("failed to read the outbox: %v", err)
+	}
+	return e, nil
+}
+
+// write saves e, replacing the file so that concurrent readers never see a
+// partial entry.
+func (e *outboxEntry) write() error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(e.path(), append(data, '\n'), 0700)
+}
+
+// writeFileAtomic writes data to path through a temporary file renamed over
+// it, creating its directory with dirPerm.
+func writeFileAtomic(path string, data []byte, dirPerm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// lockOutbox takes the exclusive lock of the outbox, waiting until ctx is
+// done while another process or delivery holds it, and returns the function
+// releasing it. Entries are only sent with the lock held, so that a queued
+// message is never sent twice.
+func lockOutbox(ctx context.Context) (func(), error) {
+	unlock, err := lockFile(ctx, filepath.Join(outboxDir(), ".lock"))
+	if err != nil && ctx.Err() == nil {
+		return nil, fmt.Errorf("failed to lock the outbox: %v", err)
+	}
+	return unlock, err
+}
+
+// lockFile takes the exclusive lock of the file at path, creating it and
+// its directory if needed, and waits until ctx is done while another
+// process holds it.
+func lockFile(ctx context.Context, path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			// Closing the file releases the lock
+			return func() { file.Close() }, nil
+		}
+		if err != syscall.EWOULDBLOCK && err != syscall.EINTR {
+			file.Close()
+			return nil, err
+		}
+
+		timer := time.NewTimer(lockPollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			file.Close()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (e *outboxEntry) remove() error {
+	if e.Attach != "" {
+		if err := os.Remove(e.Attach); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Remove(e.path()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// retryable reports whether sending again may succeed: the server could not
+// be reached, or failed on its side.
+func retryable(err error) bool {
+	switch errs.KindOf(err) {
+	case errs.KindNetwork, errs.KindUnavailable, errs.KindTimeout:
+		return true
+	}
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && (apiErr.HTTP >= 500 || apiErr.HTTP == http.StatusTooManyRequests)
+}
+
+func retryDelay(attempts int) time.Duration {
+	delay := minRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// failed records a failed attempt to send e.
+func (e *outboxEntry) failed(err error) {
+	e.Attempts++
+	e.NextAttempt = time.Now().Add(retryDelay(e.Attempts)).Unix()
+	e.LastError = err.Error()
+}
+
+// queue adds msg to the outbox after the first attempt to send it failed
+// with err. The attachment is copied, the original may be temporary.
+func queue(key string, explicit bool, name string, s *server, msg *Message, attach string, err error) (*outboxEntry, error) {
+	e := &outboxEntry{
+		ID:         outboxID(key),
+		Key:        key,
+		Explicit:   explicit,
+		Name:       name,
+		Server:     s.URL,
+		ServerName: s.Name,
+		NoCache:    msg.NoCache,
+		NoFirebase: msg.NoFirebase,
+		Queued:     time.Now().Unix(),
+	}
+	queued := *msg
+	e.Message = &queued
+
+	if attach != "" {
+		if e.Message.Filename == "" {
+			e.Message.Filename = filepath.Base(attach)
+		}
+		e.Attach = filepath.Join(outboxDir(), e.ID+".attachment")
+		if err := copyFile(attach, e.Attach); err != nil {
+			return nil, fmt.Errorf("failed to copy the attachment to the outbox: %v", err)
+		}
+	}
+
+	e.failed(err)
+	if err := e.write(); err != nil {
+		return nil, fmt.Errorf("failed to queue the message: %v", err)
+	}
+	return e, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// sendQueued sends e and removes it from the outbox, or records the failed
+// attempt.
+func sendQueued(ctx context.Context, e *outboxEntry) (*Message, error) {
+	s := knownServer(e.ServerName, e.Server)
+	published, err := s.send(ctx, e.message(), e.Attach)
+	if err != nil {
+		if ctx.Err() == nil {
+			e.failed(err)
+			if err := e.write(); err != nil {
+				fmt.Fprintln(os.Stderr, "Error updating the outbox:", err)
+			}
+		}
+		return nil, err
+	}
+
+	key := ""
+	if e.Explicit {
+		key = e.Key
+	}
+	if err := recordMessage(ctx, e.Name, key, s, published); err != nil {
+		fmt.Fprintln(os.Stderr, "Error recording the message:", err)
+	}
+	if err := e.remove(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error removing the message from the outbox:", err)
+	}
+	return published, nil
+}
+
+// flushOutbox sends the queued entries, with the lock of the outbox held. The
+// messages of a server that cannot be reached are left for later. It returns the number of messages sent and
+// the error of the first message that could not be.
+func flushOutbox(ctx context.Context, entries []*outboxEntry, out io.Writer) (int, error) {
+	sent := 0
+	var firstErr error
+	unreachable := make(map[string]bool)
+	for _, e := range entries {
+		if unreachable[e.Server] {
+			continue
+		}
+		published, err := sendQueued(ctx, e)
+		if ctx.Err() != nil {
+			return sent, ctx.Err()
+		}
+		if err != nil {
+			fmt.Fprintf(out, "Failed to send queued message %s to %s: %v\n", e.ID, e.Message.Topic, err)
+			if retryable(err) {
+				unreachable[e.Server] = true
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		fmt.Fprintf(out, "Sent queued message %s to %s (id %s)\n", e.ID, e.Message.Topic, published.ID)
+		sent++
+	}
+	return sent, firstErr
+}
+
+// flushDue sends the queued messages whose retry delay is over, after a
+// message was sent, reporting on stderr only. A process flushes the outbox
+// once, with deliverMu and the lock of the outbox held.
+func flushDue(ctx context.Context) {
+	if flushed {
+		return
+	}
+	flushed = true
+
+	entries, err := readOutbox()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading the outbox:", err)
+		return
+	}
+	var due []*outboxEntry
+	for _, e := range entries {
+		if e.due() {
+			due = append(due, e)
+		}
+	}
+	flushOutbox(ctx, due, os.Stderr)
+}
+
+// delivery is the outcome of deliver: the message as published, the entry
+// the message is queued as, or the message already sent with the same
+// idempotency key.
+type delivery struct {
+	Published *Message     `json:"published,omitempty"`
+	Queued    *outboxEntry `json:"queued,omitempty"`
+	Duplicate *sentMessage `json:"duplicate,omitempty"`
+}
+
+// deliver sends msg to s, or when s cannot be reached queues it in the
+// outbox if queueing is set. A message already queued with the same
+// idempotency key is sent instead, and a message already sent with the
+// same explicit key is not sent again.
+//
+// Unless it has one, the message is sent with a sequence ID from the first
+// attempt, kept in the outbox for the next ones, so that clients show it
+// once when an attempt reported as failed did reach the server.
+func deliver(ctx context.Context, s *server, msg *Message, attach, name, key string, queueing bool) (*delivery, error) {
+	explicit := key != ""
+	key = outboxKey(key, s, msg, attach)
+	if msg.SequenceID == "" {
+		sequenced := *msg
+		sequenced.SequenceID = sequenceID(key, explicit)
+		msg = &sequenced
+	}
+
+	deliverMu.Lock()
+	defer deliverMu.Unlock()
+	unlock, err := lockOutbox(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if explicit {
+		if sent, err := sentWithKey(key); err != nil {
+			return nil, err
+		} else if sent != nil {
+			return &delivery{Duplicate: sent}, nil
+		}
+	}
+
+	if e, err := queuedEntry(key); err != nil {
+		return nil, err
+	} else if e != nil {
+		published, err := sendQueued(ctx, e)
+		if err != nil {
+			if retryable(err) {
+				return &delivery{Queued: e}, nil
+			}
+			return nil, err
+		}
+		flushDue(ctx)
+		return &delivery{Published: published}, nil
+	}
+
+	published, err := s.send(ctx, msg, attach)
+	if err != nil {
+		if !queueing || !retryable(err) || ctx.Err() != nil {
+			return nil, err
+		}
+		e, queueErr := queue(key, explicit, name, s, msg, attach, err)
+		if queueErr != nil {
+			fmt.Fprintln(os.Stderr, "Error:", queueErr)
+			return nil, err
+		}
+		return &delivery{Queued: e}, nil
+	}
+
+	if !explicit {
+		key = ""
+	}
+	if err := recordMessage(ctx, name, key, s, published); err != nil {
+		fmt.Fprintln(os.Stderr, "Error recording the message:", err)
+	}
+	flushDue(ctx)
+	return &delivery{Published: published}, nil
+}
+
+func printDelivery(d *delivery, format string) error {
+	switch {
+	case d.Published != nil:
+		return printPublished(d.Published, format)
+	case format == "json":
+		return printJSON(d)
+	case d.Duplicate != nil:
+		fmt.Printf("Notification already sent with this idempotency key (id %s)\n", d.Duplicate.ID)
+	default:
+		fmt.Fprintf(os.Stderr, "Warning: %s, the notification is queued in the outbox as %s\n", d.Queued.LastError, d.Queued.ID)
+		fmt.Fprintf(os.Stderr, "It is sent again by the next notification, or with `support ntfy outbox flush`\n")
+	}
+	return nil
+}
+
+func ListOutbox(c *cli.Context) error {
+	entries, err := readOutbox()
+	if err != nil {
+		return err
+	}
+
+	switch c.String("format") {
+	case "json":
+		if entries == nil {
+			entries = []*outboxEntry{}
+		}
+		return printJSON(entries)
+	case "text":
+	default:
+		return errs.Usage("unknown format %q, expected text or json", c.String("format"))
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("The outbox is empty")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTOPIC\tSERVER\tQUEUED\tATTEMPTS\tNEXT ATTEMPT\tERROR")
+	for _, e := range entries {
+		server := e.Server
+		if e.ServerName != "" {
+			server = e.ServerName
+		}
+		next := time.Unix(e.NextAttempt, 0).Format("2006-01-02 15:04:05")
+		if e.due() {
+			next = "due"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", e.ID, e.Message.Topic, server, time.Unix(e.Queued, 0).Format("2006-01-02 15:04:05"), e.Attempts, next, truncate(e.LastError, 50))
+	}
+	return w.Flush()
+}
+
+// selectEntries returns the entries with the ids or keys refs, or all the
+// entries when refs is empty.
+func selectEntries(refs []string) ([]*outboxEntry, error) {
+	if len(refs) == 0 {
+		return readOutbox()
+	}
+
+	var entries []*outboxEntry
+	for _, ref := range refs {
+		e, err := queuedEntry(ref)
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			return nil, errs.NotFound("no message %s in the outbox, see `support ntfy outbox list`", ref)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func FlushOutbox(c *cli.Context) error {
+	// Read the entries with the lock held, those sent meanwhile by another
+	// process are gone
+	unlock, err := lockOutbox(c.Context)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := selectEntries(c.Args().Slice())
+	if err != nil {
+		return err
+	}
+	if c.Bool("due") {
+		var due []*outboxEntry
+		for _, e := range entries {
+			if e.due() {
+				due = append(due, e)
+			}
+		}
+		entries = due
+	}
+	if len(entries) == 0 {
+		fmt.Println("No messages to send")
+		return nil
+	}
+
+	sent, err := flushOutbox(c.Context, entries, os.Stdout)
+	if err != nil {
+		if c.Context.Err() != nil {
+			return err
+		}
+		return errs.New(errs.KindOf(err), "%d of %d queued messages sent, the others stay in the outbox", sent, len(entries))
+	}
+	return nil
+}
+
+func DropOutbox(c *cli.Context) error {
+	if !c.Args().Present() && !c.Bool("all") {
+		return errs.Usage("expected the ids of the messages to drop, or --all")
+	}
+	entries, err := selectEntries(c.Args().Slice())
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Println("The outbox is empty")
+		return nil
+	}
+
+	targets := make([]string, len(entries))
+	for i, e := range entries {
+		targets[i] = fmt.Sprintf("%s (%s, queued %s)", e.ID, e.Message.Topic, time.Unix(e.Queued, 0).Format("2006-01-02 15:04"))
+	}
+	if guard.DryRun(c) {
+		fmt.Println("Would drop queued messages:", strings.Join(targets, ", "))
+		return nil
+	}
+	if err := guard.Confirm(c, "drop", targets); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err := e.remove(); err != nil {
+			return fmt.Errorf("failed to drop %s: %v", e.ID, err)
+		}
+		fmt.Println("Dropped queued message", e.ID)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakePublish is an ntfy server recording the sequence IDs of the messages
+// published to it, and failing while unavailable is set.
+type fakePublish struct {
+	mu          sync.Mutex
+	unavailable bool
+	sequenceIDs []string
+}
+
+func (p *fakePublish) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.unavailable {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"code":50301,"http":503,"error":"unavailable"}`))
+		return
+	}
+	msg := &Message{}
+	json.NewDecoder(r.Body).Decode(msg)
+	p.sequenceIDs = append(p.sequenceIDs, msg.SequenceID)
+	msg.ID = "m" + msg.SequenceID
+	json.NewEncoder(w).Encode(msg)
+}
+
+func TestDeliverSequenceID(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	fake := &fakePublish{}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+	s := &server{URL: ts.URL}
+	ctx := context.Background()
+
+	// The same message sent twice is shown twice
+	for i := 0; i < 2; i++ {
+		if _, err := deliver(ctx, s, &Message{Topic: "ops", Message: "disk full"}, "", "", "", true); err != nil {
+			t.Fatalf("deliver failed: %v", err)
+		}
+	}
+	if len(fake.sequenceIDs) != 2 || fake.sequenceIDs[0] == "" || fake.sequenceIDs[0] == fake.sequenceIDs[1] {
+		t.Fatalf("identical messages sent with sequence IDs %q, expected two different ones", fake.sequenceIDs)
+	}
+
+	// A queued message is retried with the sequence ID of its first attempt
+	fake.unavailable = true
+	d, err := deliver(ctx, s, &Message{Topic: "ops", Message: "backup failed"}, "", "", "", true)
+	if err != nil || d.Queued == nil {
+		t.Fatalf("deliver to an unavailable server returned %+v, %v, expected a queued message", d, err)
+	}
+	first := d.Queued.Message.SequenceID
+	fake.unavailable = false
+	if _, err := deliver(ctx, s, &Message{Topic: "ops", Message: "backup failed"}, "", "", "", true); err != nil {
+		t.Fatalf("deliver failed: %v", err)
+	}
+	if len(fake.sequenceIDs) != 3 || first == "" || fake.sequenceIDs[2] != first {
+		t.Errorf("queued message sent with sequence IDs %q, expected %q", fake.sequenceIDs[2:], first)
+	}
+}
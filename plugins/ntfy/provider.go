@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.codycody31.dev/support/notify"
+)
+
+// Providers returns the notification provider of ntfy, for the channels of
+// `support notify`.
+func Providers() map[string]notify.Factory {
+	return map[string]notify.Factory{"ntfy": newProvider}
+}
+
+// provider sends the notifications of a channel to a topic, with the outbox
+// and the servers of `ntfy send`.
+type provider struct {
+	Topic string `yaml:"topic"`
+	// Server is the name of a server, the server of the topic or the
+	// default one when empty.
+	Server string `yaml:"server,omitempty"`
+	Click  string `yaml:"click,omitempty"`
+	Icon   string `yaml:"icon,omitempty"`
+	// Markdown renders the body as Markdown.
+	Markdown bool `yaml:"markdown,omitempty"`
+}
+
+func newProvider(settings map[string]interface{}) (notify.Provider, error) {
+	p := &provider{}
+	if err := notify.Decode(settings, p); err != nil {
+		return nil, err
+	}
+	if p.Topic == "" {
+		return nil, errors.New("topic is required")
+	}
+	return p, nil
+}
+
+func (p *provider) String() string {
+	if p.Server != "" {
+		return p.Topic + " on " + p.Server
+	}
+	return p.Topic
+}
+
+// Send publishes n to the topic, with its first attachment. ntfy attaches
+// one file to a message, the others are sent in messages of their own.
+func (p *provider) Send(ctx context.Context, n *notify.Notification) error {
+	s, err := resolveServer(p.Server, p.Topic)
+	if err != nil {
+		return err
+	}
+
+	msg := &Message{
+		Topic:    p.Topic,
+		Title:    n.Title,
+		Message:  n.Body,
+		Priority: int(n.Priority),
+		Tags:     n.Tags,
+		Click:    p.Click,
+		Icon:     p.Icon,
+		Markdown: p.Markdown,
+	}
+	if len(n.Attachments) == 0 {
+		return p.send(ctx, s, msg, "")
+	}
+	for i, path := range n.Attachments {
+		if i > 0 {
+			msg = &Message{Topic: p.Topic, Title: n.Title, Priority: int(n.Priority), Tags: n.Tags}
+		}
+		if err := p.send(ctx, s, msg, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *provider) send(ctx context.Context, s *server, msg *Message, attach string) error {
+	attach, cleanup, err := fitMessage(s, msg, attach, "attach")
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	d, err := deliver(ctx, s, msg, attach, "", "", true)
+	if err != nil {
+		return err
+	}
+	if d.Queued != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s, the notification to %s is queued in the ntfy outbox as %s\n", d.Queued.LastError, p.Topic, d.Queued.ID)
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/host"
+	"go.codycody31.dev/support/shlex"
+)
+
+const (
+	defaultRunTitle   = `{{ .Name }} {{ if .Success }}succeeded{{ else if .Interrupted }}was interrupted{{ else }}failed with exit code {{ .ExitCode }}{{ end }}`
+	defaultRunMessage = "{{ .Command }} ran for {{ .Duration }} on {{ .Hostname }}\n\n{{ .Output }}"
+
+	// maxTailSize bounds the output kept for the notification, whatever the
+	// length of its lines.
+	maxTailSize = 64 << 10
+	// notifyTimeout bounds the time taken to notify an interrupted run,
+	// since the interruption cancels the context of the command.
+	notifyTimeout = 30 * time.Second
+)
+
+// Run is the outcome of a command run by `ntfy run`, available to the
+// templates of the notification.
+type Run struct {
+	// Name is the name of the program, Command the whole command line.
+	Name    string
+	Command string
+	Args    []string
+	// ExitCode is 127 when the command could not be started.
+	ExitCode    int
+	Success     bool
+	Interrupted bool
+	Start       time.Time
+	End         time.Time
+	Duration    time.Duration
+	// Output is the last lines of the output, stdout and stderr
+	// interleaved, and Lines the number of lines of the whole output.
+	Output   string
+	Lines    int
+	Hostname string
+}
+
+func NtfyRun(c *cli.Context) error {
+	args := c.Args().Slice()
+	if len(args) == 0 {
+		return errs.Usage("expected a command to run, e.g. support ntfy run --topic builds -- make release")
+	}
+	if c.String("topic") == "" {
+		return errs.Usage("--topic is required")
+	}
+
+	attachLog := c.String("attach-log")
+	if attachLog != "never" && attachLog != "failure" && attachLog != "always" {
+		return errs.Usage("unknown --attach-log %q, expected never, failure or always", attachLog)
+	}
+
+	// Check the templates and priorities before running the command rather
+	// than failing after it
+	title, err := template.New("title").Option("missingkey=error").Parse(c.String("title"))
+	if err != nil {
+		return errs.Usage("invalid title template: %v", err)
+	}
+	message, err := template.New("message").Option("missingkey=error").Parse(c.String("message"))
+	if err != nil {
+		return errs.Usage("invalid message template: %v", err)
+	}
+	for _, tmpl := range []*template.Template{title, message} {
+		if _, err := render(tmpl, &Run{}); err != nil {
+			return errs.Usage("invalid %s template: %v", tmpl.Name(), err)
+		}
+	}
+	successPriority, err := parsePriority(c.String("success-priority"))
+	if err != nil {
+		return errs.Usage("%v", err)
+	}
+	failurePriority, err := parsePriority(c.String("failure-priority"))
+	if err != nil {
+		return errs.Usage("%v", err)
+	}
+	if c.Int("lines") < 0 {
+		return errs.Usage("--lines must be 0 or more, got %d", c.Int("lines"))
+	}
+
+	logFile, err := os.CreateTemp("", "support-ntfy-run-*.log")
+	if err != nil {
+		return fmt.Errorf("failed to create the log file: %v", err)
+	}
+	defer os.Remove(logFile.Name())
+	defer logFile.Close()
+
+	run, runErr := runCommand(c.Context, args, c.Int("lines"), logFile)
+
+	msg := &Message{
+		Topic:    c.String("topic"),
+		Priority: successPriority,
+		Tags:     c.StringSlice("success-tags"),
+	}
+	if !run.Success {
+		msg.Priority = failurePriority
+		msg.Tags = c.StringSlice("failure-tags")
+	}
+	if msg.Title, err = render(title, run); err != nil {
+		return errs.Usage("failed to render the title: %v", err)
+	}
+	if msg.Message, err = render(message, run); err != nil {
+		return errs.Usage("failed to render the message: %v", err)
+	}
+
+	attach := ""
+	if attachLog == "always" || (attachLog == "failure" && !run.Success) {
+		attach = logFile.Name()
+		msg.Filename = fmt.Sprintf("%s-%s.log", run.Name, run.Start.Format("20060102-150405"))
+	}
+
+	ctx := c.Context
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+	}
+
+	server, err := resolveServer(c.String("server"), msg.Topic)
+	if err == nil {
+		// The output is cut to fit rather than attached, the log is the
+		// attachment
+		var cleanup func()
+		attach, cleanup, err = fitMessage(server, msg, attach, "truncate")
+		defer cleanup()
+	}
+	if err == nil {
+		var d *delivery
+		d, err = deliver(ctx, server, msg, attach, "", c.String("idempotency-key"), c.Bool("outbox"))
+		switch {
+		case err != nil:
+		case d.Published != nil:
+			fmt.Fprintf(os.Stderr, "Notification sent to %s (id %s)\n", msg.Topic, d.Published.ID)
+		case d.Duplicate != nil:
+			fmt.Fprintf(os.Stderr, "Notification already sent with this idempotency key (id %s)\n", d.Duplicate.ID)
+		default:
+			fmt.Fprintf(os.Stderr, "Warning: %s, the notification is queued in the outbox as %s\n", d.Queued.LastError, d.Queued.ID)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error sending the notification:", err)
+	}
+
+	// The exit code of the command wins over a failed notification
+	switch {
+	case runErr != nil:
+		return runErr
+	case run.Interrupted:
+		return fmt.Errorf("%s was interrupted: %w", run.Name, c.Context.Err())
+	case !run.Success:
+		return errs.New(errs.KindFromExitCode(run.ExitCode), "%s exited with code %d", run.Name, run.ExitCode)
+	}
+	return err
+}
+
+// runCommand runs args, copying its output to the terminal, to log and to
+// the last lines of the run. The error is only set when the command could
+// not be started.
+func runCommand(ctx context.Context, args []string, lines int, log io.Writer) (*Run, error) {
+	run := &Run{
+		Name:    filepath.Base(args[0]),
+		Command: quote(args),
+		Args:    args,
+		Start:   time.Now(),
+	}
+	run.Hostname, _ = os.Hostname()
+
+	output := &capture{log: log, lines: lines}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = io.MultiWriter(os.Stdout, output)
+	cmd.Stderr = io.MultiWriter(os.Stderr, output)
+
+	err := host.Run(ctx, cmd)
+	run.End = time.Now()
+	run.Duration = roundDuration(run.End.Sub(run.Start))
+
+	var startErr error
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		run.Success = true
+	case ctx.Err() != nil:
+		run.Interrupted = true
+		run.ExitCode = errs.KindOf(ctx.Err()).ExitCode()
+	case errors.As(err, &exitErr):
+		run.ExitCode = exitErr.ExitCode()
+		if run.ExitCode < 0 {
+			// Killed by a signal
+			run.ExitCode = errs.KindUnknown.ExitCode()
+		}
+	default:
+		// Reported like a shell reports a missing command
+		run.ExitCode = 127
+		fmt.Fprintln(output, err)
+		startErr = errs.NotFound("failed to run %s: %v", run.Name, err)
+	}
+
+	run.Output, run.Lines = output.tail()
+	return run, startErr
+}
+
+// capture keeps the whole output in log and its last lines in memory. The
+// command writes stdout and stderr concurrently.
+type capture struct {
+	mu    sync.Mutex
+	log   io.Writer
+	lines int
+	buf   []byte
+	count int
+}
+
+func (c *capture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := c.log.Write(p); err != nil {
+		return 0, err
+	}
+	c.count += bytes.Count(p, []byte("\n"))
+
+	// Keep the last lines, and the start of the next one
+	c.buf = append(c.buf, p...)
+	if bytes.Count(c.buf, []byte("\n")) > c.lines {
+		c.buf = lastLines(c.buf, c.lines)
+	}
+	if len(c.buf) > maxTailSize {
+		c.buf = c.buf[len(c.buf)-maxTailSize:]
+	}
+	return len(p), nil
+}
+
+// tail returns the last lines of the output and the number of lines of the
+// whole output.
+func (c *capture) tail() (string, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count := c.count
+	if len(c.buf) > 0 && c.buf[len(c.buf)-1] != '\n' {
+		count++
+	}
+	if c.lines == 0 {
+		return "", count
+	}
+	return string(lastLines(bytes.TrimRight(c.buf, "\n"), c.lines-1)), count
+}
+
+// lastLines returns what follows the (n+1)-th newline from the end of b, or
+// b when it has n newlines or fewer.
+func lastLines(b []byte, n int) []byte {
+	end := len(b)
+	for i := 0; i <= n; i++ {
+		end = bytes.LastIndexByte(b[:end], '\n')
+		if end < 0 {
+			return b
+		}
+	}
+	return b[end+1:]
+}
+
+func render(tmpl *template.Template, run *Run) (string, error) {
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, run); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(time.Second)
+	}
+	return d.Round(time.Millisecond)
+}
+
+func quote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shlex.Quote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCaptureTail(t *testing.T) {
+	tests := []struct {
+		output string
+		lines  int
+		tail   string
+		count  int
+	}{
+		{"", 3, "", 0},
+		{"a\nb\nc\n", 2, "b\nc", 3},
+		{"a\nb\nc", 2, "b\nc", 3},
+		{"a\nb\nc\n", 5, "a\nb\nc", 3},
+		{"a\nb\nc", 1, "c", 3},
+		{"a\nb\nc\n", 0, "", 3},
+		{"a\nb\nc", 0, "", 3},
+		{"a\n\nb\n\n", 3, "\nb", 4},
+	}
+
+	for _, test := range tests {
+		// Whole, and one byte at a time as a command may write it
+		for _, size := range []int{len(test.output) + 1, 1} {
+			output := &capture{log: io.Discard, lines: test.lines}
+			for rest := test.output; rest != ""; {
+				n := size
+				if n > len(rest) {
+					n = len(rest)
+				}
+				output.Write([]byte(rest[:n]))
+				rest = rest[n:]
+			}
+
+			tail, count := output.tail()
+			if tail != test.tail || count != test.count {
+				t.Errorf("tail of %q with --lines %d written by %d bytes is %q and %d lines, expected %q and %d",
+					test.output, test.lines, size, tail, count, test.tail, test.count)
+			}
+		}
+	}
+}
+
+func TestCaptureTailSize(t *testing.T) {
+	output := &capture{log: io.Discard, lines: 2}
+	line := strings.Repeat("x", maxTailSize) + "\n"
+	for i := 0; i < 3; i++ {
+		output.Write([]byte(line))
+	}
+
+	tail, count := output.tail()
+	if len(tail) > maxTailSize || count != 3 {
+		t.Errorf("tail of %d bytes and %d lines, expected at most %d bytes and 3 lines", len(tail), count, maxTailSize)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/errs"
+)
+
+func NtfySend(c *cli.Context) error {
+	format := c.String("format")
+	if format != "text" && format != "json" {
+		return errs.Usage("unknown format %q, expected text or json", format)
+	}
+	if !c.IsSet("topic") && !c.IsSet("json") {
+		return errs.Usage("both topic and message are required")
+	}
+
+	msg, err := messageFromFlags(c)
+	if err != nil {
+		return err
+	}
+	if err := validateMessage(c, msg); err != nil {
+		return err
+	}
+
+	server, err := resolveServer(c.String("server"), msg.Topic)
+	if err != nil {
+		return err
+	}
+	attach, cleanup, err := fitMessage(server, msg, c.String("attach"), c.String("oversize"))
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	d, err := deliver(c.Context, server, msg, attach, c.String("name"), c.String("idempotency-key"), c.Bool("outbox"))
+	if err != nil {
+		return err
+	}
+
+	if err := rememberTopic(msg.Topic); err != nil {
+		fmt.Fprintln(os.Stderr, "Error saving topic history:", err)
+	}
+	return printDelivery(d, format)
+}
+
+// send publishes msg, with the file at attach attached when it is set.
+func (s *server) send(ctx context.Context, msg *Message, attach string) (*Message, error) {
+	if attach != "" {
+		return s.upload(ctx, msg, attach)
+	}
+	return s.publish(ctx, msg)
+}
+
+func printPublished(published *Message, format string) error {
+	if format == "json" {
+		return printJSON(published)
+	}
+
+	if published.scheduled() {
+		fmt.Printf("Notification scheduled for %s (id %s)\n", time.Unix(published.Time, 0).Format(time.RFC3339), published.ID)
+	} else {
+		fmt.Printf("Notification sent successfully! (id %s)\n", published.ID)
+	}
+	if a := published.Attachment; a != nil {
+		fmt.Printf("Attachment: %s", a.URL)
+		if a.Size > 0 {
+			fmt.Printf(" (%s)", formatSize(a.Size))
+		}
+		fmt.Println()
+		if a.Expires > 0 {
+			fmt.Printf("The attachment expires at %s\n", time.Unix(a.Expires, 0).Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// messageFromFlags returns the message described by the flags of send and
+// messages update. The message given with --json, if any, is the base the
+// other flags override. The title and message are rendered last.
+func messageFromFlags(c *cli.Context) (*Message, error) {
+	if err := checkFlags(c); err != nil {
+		return nil, err
+	}
+	msg := &Message{}
+	if c.IsSet("json") {
+		if err := readJSONMessage(c.String("json"), msg); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.IsSet("topic") {
+		msg.Topic = c.String("topic")
+	}
+	if c.IsSet("title") {
+		msg.Title = c.String("title")
+	}
+	if c.IsSet("priority") {
+		priority, err := parsePriority(c.String("priority"))
+		if err != nil {
+			return nil, errs.Usage("%v", err)
+		}
+		msg.Priority = priority
+	}
+	if c.IsSet("tags") {
+		msg.Tags = c.StringSlice("tags")
+	}
+	if c.IsSet("click") {
+		msg.Click = c.String("click")
+	}
+	if c.IsSet("icon") {
+		msg.Icon = c.String("icon")
+	}
+	if c.IsSet("markdown") {
+		msg.Markdown = c.Bool("markdown")
+	}
+	if c.IsSet("email") {
+		msg.Email = c.String("email")
+	}
+	if c.IsSet("attach-url") {
+		msg.Attach = c.String("attach-url")
+	}
+	if c.IsSet("filename") {
+		msg.Filename = c.String("filename")
+	}
+	if c.IsSet("actions") {
+		actions, err := parseActions(c.String("actions"))
+		if err != nil {
+			return nil, errs.Usage("%v", err)
+		}
+		msg.Actions = actions
+	}
+
+	switch {
+	case c.IsSet("at") && c.IsSet("delay"):
+		return nil, errs.Usage("--at and --delay cannot be used together")
+	case c.IsSet("at"):
+		at, err := parseTime(c.String("at"), time.Now())
+		if err != nil {
+			return nil, errs.Usage("%v", err)
+		}
+		msg.Delay = strconv.FormatInt(at.Unix(), 10)
+	case c.IsSet("delay"):
+		msg.Delay = c.String("delay")
+	}
+	msg.NoCache = !c.Bool("cache")
+	msg.NoFirebase = !c.Bool("firebase")
+
+	// Read piped input once the flags are known to be valid
+	message, ok, err := readMessageInput(c)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		msg.Message = message
+	}
+
+	if err := renderMessage(c, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// validateMessage checks that msg can be published.
+func validateMessage(c *cli.Context, msg *Message) error {
+	attached := msg.Attach != "" || c.String("attach") != ""
+	if msg.Topic == "" || (msg.Message == "" && !attached) {
+		return errs.Usage("both topic and message are required")
+	}
+	if msg.Attach != "" && c.String("attach") != "" {
+		return errs.Usage("--attach and --attach-url cannot be used together")
+	}
+	if len(msg.Actions) > 3 {
+		return errs.Usage("ntfy allows at most 3 actions, got %d", len(msg.Actions))
+	}
+	return nil
+}
+
+// timeLayouts are the layouts accepted by --at, in local time unless they
+// have a zone.
+var timeLayouts = []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02T15:04", "15:04"}
+
+// parseTime parses the time s, which must be after now. A time of day
+// without a date is the next one.
+func parseTime(s string, now time.Time) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err != nil {
+			continue
+		}
+		if layout == "15:04" {
+			t = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, time.Local)
+			if !t.After(now) {
+				t = t.AddDate(0, 0, 1)
+			}
+		}
+		if !t.After(now) {
+			return time.Time{}, fmt.Errorf("%s is in the past", s)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected 15:04, \"2006-01-02 15:04\" or RFC 3339", s)
+}
+
+// readJSONMessage decodes the message object s into msg, read from stdin
+// when s is "-".
+func readJSONMessage(s string, msg *Message) error {
+	data := []byte(s)
+	if s == "-" {
+		var err error
+		if data, err = io.ReadAll(os.Stdin); err != nil {
+			return fmt.Errorf("failed to read the message from stdin: %v", err)
+		}
+	}
+	if err := json.Unmarshal(data, msg); err != nil {
+		return errs.Usage("invalid JSON message: %v", err)
+	}
+	return nil
+}
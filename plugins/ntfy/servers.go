@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v2"
+)
+
+// serverConfig is a named server in the "servers" setting.
+type serverConfig struct {
+	URL      string `yaml:"url"`
+	Token    string `yaml:"token,omitempty"`
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	// MessageLimit is the message-size-limit of the server, when it is not
+	// the default.
+	MessageLimit int `yaml:"message-limit,omitempty"`
+}
+
+func (sc *serverConfig) auth() string {
+	switch {
+	case sc.Token != "":
+		return "token"
+	case sc.Username != "":
+		return "basic (" + sc.Username + ")"
+	}
+	return "none"
+}
+
+// decodeSetting decodes the setting key into v. Settings are loaded as
+// generic maps, they are decoded the way config.yaml is decoded.
+func decodeSetting(key string, v interface{}) error {
+	value, exists := config.GetPluginSetting("ntfy", key)
+	if !exists || value == nil {
+		return nil
+	}
+
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return errs.Config("invalid %s setting of ntfy: %v", key, err)
+	}
+	if err := yaml.UnmarshalStrict(data, v); err != nil {
+		return errs.Config("invalid %s setting of ntfy: %v", key, err)
+	}
+	return nil
+}
+
+// namedServers returns the servers of the "servers" setting by name.
+func namedServers() (map[string]*serverConfig, error) {
+	servers := make(map[string]*serverConfig)
+	if err := decodeSetting("servers", &servers); err != nil {
+		return nil, err
+	}
+	return servers, nil
+}
+
+// topicServers returns the server names of the "topics" setting by topic.
+func topicServers() (map[string]string, error) {
+	topics := make(map[string]string)
+	if err := decodeSetting("topics", &topics); err != nil {
+		return nil, err
+	}
+	return topics, nil
+}
+
+// serverNames returns the names of the servers, for completion.
+func serverNames() []string {
+	servers, _ := namedServers()
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func defaultServerName() string {
+	name, _ := config.GetPluginSetting("ntfy", "default-server")
+	if name, ok := name.(string); ok {
+		return name
+	}
+	return ""
+}
+
+// namedServer returns the server called name in the settings.
+func namedServer(name string) (*server, error) {
+	servers, err := namedServers()
+	if err != nil {
+		return nil, err
+	}
+	sc, ok := servers[name]
+	if !ok {
+		return nil, errs.NotFound("unknown ntfy server %s, see `support ntfy servers list`", name)
+	}
+	return &server{Name: name, URL: sc.URL, Token: sc.Token, Username: sc.Username, Password: sc.Password, MessageLimit: sc.MessageLimit}, nil
+}
+
+// resolveServer returns the server to use for topic: the server called name
+// when it is set, else the server the topic is mapped to, else the default
+// server, else the server set by `ntfy configure`.
+func resolveServer(name, topic string) (*server, error) {
+	if name != "" {
+		return namedServer(name)
+	}
+
+	topics, err := topicServers()
+	if err != nil {
+		return nil, err
+	}
+	if name, ok := topics[topic]; ok {
+		return namedServer(name)
+	}
+
+	if name := defaultServerName(); name != "" {
+		return namedServer(name)
+	}
+	return defaultServerSettings(), nil
+}
+
+func AddServer(c *cli.Context) error {
+	name := c.Args().First()
+	if name == "" {
+		return errs.Usage("a server name is required")
+	}
+	if c.Args().Len() > 1 {
+		return errs.Usage("unexpected arguments %q, flags must come before the server name", c.Args().Tail())
+	}
+	if c.IsSet("token") && c.IsSet("username") {
+		return errs.Usage("use either --token or --username, not both")
+	}
+
+	sc := &serverConfig{
+		URL:          strings.TrimSuffix(c.String("url"), "/"),
+		Token:        c.String("token"),
+		Username:     c.String("username"),
+		Password:     c.String("password"),
+		MessageLimit: c.Int("message-limit"),
+	}
+	if sc.Username != "" && !c.IsSet("password") {
+		password, err := readPassword(sc.Username)
+		if err != nil {
+			return err
+		}
+		sc.Password = password
+	}
+
+	servers, err := namedServers()
+	if err != nil {
+		return err
+	}
+	servers[name] = sc
+	if err := config.UpdatePluginSetting("ntfy", "servers", servers); err != nil {
+		return fmt.Errorf("failed to save the ntfy servers: %v", err)
+	}
+
+	if topics := c.StringSlice("topic"); len(topics) > 0 {
+		mapping, err := topicServers()
+		if err != nil {
+			return err
+		}
+		for _, topic := range topics {
+			mapping[topic] = name
+		}
+		if err := config.UpdatePluginSetting("ntfy", "topics", mapping); err != nil {
+			return fmt.Errorf("failed to save the ntfy topics: %v", err)
+		}
+	}
+	if c.Bool("default") {
+		if err := config.UpdatePluginSetting("ntfy", "default-server", name); err != nil {
+			return fmt.Errorf("failed to set the default ntfy server: %v", err)
+		}
+	}
+
+	fmt.Printf("Ntfy server %s set to %s\n", name, sc.URL)
+	return nil
+}
+
+// readPassword prompts for the password of username without echoing it, or
+// reads it from stdin when it is not a terminal.
+func readPassword(username string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", errs.Usage("no password given for %s, use --password or pass it on stdin", username)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Password for %s: ", username)
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the password: %v", err)
+	}
+	return string(password), nil
+}
+
+func RemoveServer(c *cli.Context) error {
+	name := c.Args().First()
+	if name == "" {
+		return errs.Usage("a server name is required")
+	}
+
+	servers, err := namedServers()
+	if err != nil {
+		return err
+	}
+	if _, ok := servers[name]; !ok {
+		return errs.NotFound("unknown ntfy server %s", name)
+	}
+	delete(servers, name)
+	if err := config.UpdatePluginSetting("ntfy", "servers", servers); err != nil {
+		return fmt.Errorf("failed to save the ntfy servers: %v", err)
+	}
+
+	// Topics mapped to the server fall back to the default server
+	topics, err := topicServers()
+	if err != nil {
+		return err
+	}
+	var unmapped []string
+	for topic, server := range topics {
+		if server == name {
+			delete(topics, topic)
+			unmapped = append(unmapped, topic)
+		}
+	}
+	if len(unmapped) > 0 {
+		if err := config.UpdatePluginSetting("ntfy", "topics", topics); err != nil {
+			return fmt.Errorf("failed to save the ntfy topics: %v", err)
+		}
+		sort.Strings(unmapped)
+		fmt.Printf("Topics %s now use the default server\n", strings.Join(unmapped, ", "))
+	}
+	if defaultServerName() == name {
+		if err := config.UpdatePluginSetting("ntfy", "default-server", ""); err != nil {
+			return fmt.Errorf("failed to unset the default ntfy server: %v", err)
+		}
+		fmt.Println("The default server is now the one set by `support ntfy configure`")
+	}
+
+	fmt.Printf("Removed ntfy server %s\n", name)
+	return nil
+}
+
+func ListServers(c *cli.Context) error {
+	servers, err := namedServers()
+	if err != nil {
+		return err
+	}
+	topics, err := topicServers()
+	if err != nil {
+		return err
+	}
+	defaultName := defaultServerName()
+
+	topicsOf := make(map[string][]string)
+	for topic, name := range topics {
+		topicsOf[name] = append(topicsOf[name], topic)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tURL\tAUTH\tDEFAULT\tTOPICS")
+
+	// The server of `ntfy configure` is used when no default is set
+	configured := defaultServerSettings()
+	auth := "none"
+	if configured.Token != "" {
+		auth = "token"
+	}
+	fmt.Fprintf(w, "(configure)\t%s\t%s\t%s\t-\n", configured.URL, auth, yesNo(defaultName == ""))
+
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sc := servers[name]
+		sort.Strings(topicsOf[name])
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, sc.URL, sc.auth(), yesNo(name == defaultName), dash(strings.Join(topicsOf[name], ",")))
+	}
+	return w.Flush()
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func TestServers(c *cli.Context) error {
+	var servers []*server
+	if c.Args().Present() {
+		for _, name := range c.Args().Slice() {
+			s, err := namedServer(name)
+			if err != nil {
+				return err
+			}
+			servers = append(servers, s)
+		}
+	} else {
+		s, err := resolveServer("", "")
+		if err != nil {
+			return err
+		}
+		servers = append(servers, s)
+	}
+
+	var failed []string
+	var firstErr error
+	for _, s := range servers {
+		if err := testServer(c, s); err != nil {
+			fmt.Printf("%s: FAILED: %v\n", s, err)
+			if c.Context.Err() != nil {
+				return err
+			}
+			failed = append(failed, s.String())
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return errs.New(errs.KindOf(firstErr), "%d of %d servers failed: %s", len(failed), len(servers), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// testServer checks that the credentials of s are accepted, with the account
+// endpoint of the server.
+func testServer(c *cli.Context, s *server) error {
+	account, err := s.account(c.Context)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case account.Username == "" || account.Username == "*":
+		if s.Token != "" || s.Username != "" {
+			return errs.Auth("the server ignored the credentials, is access control enabled?")
+		}
+		fmt.Printf("%s: ok, anonymous access to %s\n", s, s.URL)
+	case account.Role != "":
+		fmt.Printf("%s: ok, authenticated to %s as %s (%s)\n", s, s.URL, account.Username, account.Role)
+	default:
+		fmt.Printf("%s: ok, authenticated to %s as %s\n", s, s.URL, account.Username)
+	}
+	return nil
+}
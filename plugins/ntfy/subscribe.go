@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/host"
+	"go.codycody31.dev/support/runner"
+	"go.codycody31.dev/support/shlex"
+)
+
+const (
+	// maxReconnectWait caps the delay before reconnecting a dropped
+	// subscription.
+	maxReconnectWait = 30 * time.Second
+	// maxMessageSize is the size of the longest line of the stream.
+	maxMessageSize = 1 << 20
+)
+
+func NtfySubscribe(c *cli.Context) error {
+	topics := c.Args().Slice()
+	if len(topics) == 0 {
+		return errs.Usage("at least one topic is required")
+	}
+
+	format := c.String("format")
+	if format != "text" && format != "json" {
+		return errs.Usage("unknown format %q, expected text or json", format)
+	}
+
+	query, err := subscribeQuery(c)
+	if err != nil {
+		return err
+	}
+
+	var handler []string
+	switch {
+	case c.IsSet("command") && c.IsSet("routine"):
+		return errs.Usage("--command and --routine cannot be used together")
+	case c.IsSet("routine"):
+		handler = []string{"routine", "run", c.String("routine")}
+	case c.IsSet("command"):
+		handler, err = shlex.Split(c.String("command"))
+		if err != nil {
+			return errs.Usage("invalid command: %v", err)
+		}
+	}
+
+	server, err := subscriptionServer(c.String("server"), topics)
+	if err != nil {
+		return err
+	}
+	path := "/" + strings.Join(topics, ",") + "/json"
+	handle := func(msg *Message) error {
+		if err := printMessage(msg, format); err != nil {
+			return err
+		}
+		if handler != nil {
+			runHandler(c.Context, handler, msg)
+		}
+		return nil
+	}
+
+	if c.Bool("poll") {
+		return server.stream(c.Context, path, query, handle)
+	}
+
+	// Reconnect until interrupted, resuming after the last message
+	wait := time.Second
+	for {
+		received := false
+		connected := time.Now()
+		err := server.stream(c.Context, path, query, func(msg *Message) error {
+			received = true
+			query.Set("since", msg.ID)
+			return handle(msg)
+		})
+		if c.Context.Err() != nil {
+			return nil
+		}
+		if k := errs.KindOf(err); k == errs.KindAuth || k == errs.KindNotFound || k == errs.KindUsage {
+			return err
+		}
+		if received {
+			wait = time.Second
+		}
+		if !query.Has("since") {
+			// Do not miss the messages published while reconnecting
+			query.Set("since", strconv.FormatInt(connected.Unix(), 10))
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Subscription lost, reconnecting in %s: %v\n", wait, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Subscription closed by the server, reconnecting in %s\n", wait)
+		}
+		select {
+		case <-c.Context.Done():
+			return nil
+		case <-time.After(wait):
+		}
+		if wait *= 2; wait > maxReconnectWait {
+			wait = maxReconnectWait
+		}
+	}
+}
+
+// subscriptionServer returns the server of topics, which must all be on the
+// same server.
+func subscriptionServer(name string, topics []string) (*server, error) {
+	var s *server
+	for _, topic := range topics {
+		topicServer, err := resolveServer(name, topic)
+		if err != nil {
+			return nil, err
+		}
+		if s != nil && topicServer.URL != s.URL {
+			return nil, errs.Usage("%s and %s are on different servers, subscribe to them separately", topics[0], topic)
+		}
+		s = topicServer
+	}
+	return s, nil
+}
+
+// subscribeQuery returns the query of the subscription, with the filters
+// applied by the server.
+func subscribeQuery(c *cli.Context) (url.Values, error) {
+	query := url.Values{}
+	if since := c.String("since"); since != "" {
+		query.Set("since", since)
+	}
+	if c.Bool("poll") {
+		query.Set("poll", "1")
+	}
+
+	if c.IsSet("priority") {
+		var priorities []string
+		for _, p := range strings.Split(c.String("priority"), ",") {
+			priority, err := parsePriority(strings.TrimSpace(p))
+			if err != nil {
+				return nil, errs.Usage("%v", err)
+			}
+			priorities = append(priorities, strconv.Itoa(priority))
+		}
+		query.Set("priority", strings.Join(priorities, ","))
+	}
+	if tags := c.StringSlice("tags"); len(tags) > 0 {
+		query.Set("tags", strings.Join(tags, ","))
+	}
+	if c.IsSet("message") {
+		query.Set("message", c.String("message"))
+	}
+	if c.IsSet("title") {
+		query.Set("title", c.String("title"))
+	}
+	return query, nil
+}
+
+// stream reads the JSON stream at path and calls fn with every message,
+// until the stream ends, fn fails or ctx is cancelled.
+func (s *server) stream(ctx context.Context, path string, query url.Values, fn func(*Message) error) error {
+	req, err := s.newRequest(ctx, "GET", path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	client, err := host.HTTPClient("ntfy")
+	if err != nil {
+		return err
+	}
+	// The timeout of the client would end the stream
+	streaming := *client
+	streaming.Timeout = 0
+
+	resp, err := streaming.Do(req)
+	if err != nil {
+		return errs.Network("failed to subscribe: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), maxMessageSize)
+	for scanner.Scan() {
+		msg := &Message{}
+		if err := json.Unmarshal(scanner.Bytes(), msg); err != nil {
+			return errs.RemoteAPI("failed to decode the stream of ntfy: %v", err)
+		}
+		// Other events are open, keepalive and poll requests
+		if msg.Event != "message" {
+			continue
+		}
+		if err := fn(msg); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return errs.Network("subscription interrupted: %w", err)
+	}
+	return nil
+}
+
+func printMessage(msg *Message, format string) error {
+	if format == "json" {
+		// One message per line, so that the output can be streamed
+		return json.NewEncoder(os.Stdout).Encode(msg)
+	}
+
+	line := fmt.Sprintf("%s [%s] ", time.Unix(msg.Time, 0).Format("2006-01-02 15:04:05"), msg.Topic)
+	if msg.Title != "" {
+		line += msg.Title + ": "
+	}
+	line += msg.Message
+
+	var details []string
+	if msg.Priority != 0 && msg.Priority != priorities["default"] {
+		details = append(details, fmt.Sprintf("priority %d", msg.Priority))
+	}
+	if len(msg.Tags) > 0 {
+		details = append(details, "tags "+strings.Join(msg.Tags, ","))
+	}
+	if msg.Attachment != nil {
+		details = append(details, "attachment "+msg.Attachment.URL)
+	}
+	if len(details) > 0 {
+		line += " (" + strings.Join(details, ", ") + ")"
+	}
+	fmt.Println(line)
+	return nil
+}
+
+// runHandler runs the support command args for msg, with the fields of the
+// message as NTFY_* environment variables. A failing command is reported and
+// does not end the subscription.
+func runHandler(ctx context.Context, args []string, msg *Message) {
+	cmd, err := runner.Command(args...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error running the handler:", err)
+		return
+	}
+	cmd.Env = append(cmd.Env, messageEnv(msg)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := host.Run(ctx, cmd); err != nil && ctx.Err() == nil {
+		fmt.Fprintf(os.Stderr, "Error handling message %s: %s: %v\n", msg.ID, strings.Join(args, " "), err)
+	}
+}
+
+// messageEnv returns the environment variables describing msg.
+func messageEnv(msg *Message) []string {
+	raw, _ := json.Marshal(msg)
+	priority := msg.Priority
+	if priority == 0 {
+		priority = priorities["default"]
+	}
+	env := []string{
+		"NTFY_ID=" + msg.ID,
+		"NTFY_TIME=" + strconv.FormatInt(msg.Time, 10),
+		"NTFY_TOPIC=" + msg.Topic,
+		"NTFY_TITLE=" + msg.Title,
+		"NTFY_MESSAGE=" + msg.Message,
+		"NTFY_PRIORITY=" + strconv.Itoa(priority),
+		"NTFY_TAGS=" + strings.Join(msg.Tags, ","),
+		"NTFY_CLICK=" + msg.Click,
+		"NTFY_RAW=" + string(raw),
+	}
+	if msg.Attachment != nil {
+		env = append(env, "NTFY_ATTACHMENT_NAME="+msg.Attachment.Name, "NTFY_ATTACHMENT_URL="+msg.Attachment.URL)
+	}
+	return env
+}
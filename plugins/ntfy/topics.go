@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.codycody31.dev/support/config"
+)
+
+// maxTopicHistory is the number of topics remembered for completion.
+const maxTopicHistory = 50
+
+func topicHistoryPath() string {
+	return filepath.Join(config.SupportDir(), "ntfy", "topics")
+}
+
+// topicHistory returns the topics notifications were sent to, most recent
+// last.
+func topicHistory() []string {
+	file, err := os.Open(topicHistoryPath())
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var topics []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if topic := strings.TrimSpace(scanner.Text()); topic != "" {
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
+
+func rememberTopic(topic string) error {
+	topics := []string{}
+	for _, t := range topicHistory() {
+		if t != topic {
+			topics = append(topics, t)
+		}
+	}
+	topics = append(topics, topic)
+	if len(topics) > maxTopicHistory {
+		topics = topics[len(topics)-maxTopicHistory:]
+	}
+
+	path := topicHistoryPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Join(topics, "\n")+"\n"), 0644)
+}
@@ -7,12 +7,23 @@ import (
 	"strings"
 
 	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/host"
+	"go.codycody31.dev/support/plugins"
 )
 
 func Name() string {
 	return "rest"
 }
 
+func Manifest() plugins.Manifest {
+	return plugins.Manifest{
+		Name:        "rest",
+		Version:     "0.1.0",
+		Description: "Send requests to REST APIs",
+	}
+}
+
 func SetupCommands() []*cli.Command {
 	return []*cli.Command{
 		{
@@ -58,15 +69,24 @@ func SetupCommands() []*cli.Command {
 
 func RestGet(c *cli.Context) error {
 	url := c.String("url")
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(c.Context, "GET", url, nil)
 	if err != nil {
-		return fmt.Errorf("failed to make GET request: %v", err)
+		return errs.Usage("invalid URL %s: %v", url, err)
+	}
+
+	client, err := host.HTTPClient("rest")
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errs.Network("failed to make GET request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("failed to read response body: %v", err)
+		return fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	fmt.Println(string(body))
@@ -76,15 +96,25 @@ func RestGet(c *cli.Context) error {
 func RestPost(c *cli.Context) error {
 	url := c.String("url")
 	data := c.String("data")
-	resp, err := http.Post(url, "application/json", strings.NewReader(data))
+	req, err := http.NewRequestWithContext(c.Context, "POST", url, strings.NewReader(data))
+	if err != nil {
+		return errs.Usage("invalid URL %s: %v", url, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client, err := host.HTTPClient("rest")
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to make POST request: %v", err)
+		return errs.Network("failed to make POST request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("failed to read response body: %v", err)
+		return fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	fmt.Println(string(body))
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"go.codycody31.dev/support/completion"
+	"go.codycody31.dev/support/routine"
+
+	"github.com/urfave/cli/v2"
+)
+
+var RoutineCommand = &cli.Command{
+	Name:  "routine",
+	Usage: "Run multi-step routines",
+	Description: `Routines are YAML files in .support/routines of the current directory or
+   its parents, and in ~/.support/routines. Each step runs a command of
+   support or of a plugin:
+
+     name: cleanup-previews
+     vars:
+       pattern: '^pr-'
+     steps:
+       - id: delete
+         run: caprover delete --regex '{{ .Vars.pattern }}'
+       - id: notify
+         run: ntfy send --topic ops --message '{{ .Steps.delete.Output }}'
+     on_failure:
+       - run: ntfy send --topic ops --message 'cleanup failed at {{ .Failed.ID }}'`,
+	Subcommands: []*cli.Command{
+		{
+			Name:   "list",
+			Usage:  "List routines",
+			Action: routine.ListRoutines,
+		},
+		{
+			Name:      "run",
+			Usage:     "Run a routine",
+			ArgsUsage: "<name>",
+			Action:    routine.RunRoutine,
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:  "var",
+					Usage: "Set a variable, as key=value",
+				},
+				&cli.BoolFlag{
+					Name:    "dry-run",
+					Aliases: []string{"d"},
+					Usage:   "Print the steps without running them",
+				},
+			},
+		},
+	},
+}
+
+func init() {
+	completion.Register("routine run", func(command []string, flag, prefix string) []string {
+		if flag != "" {
+			return nil
+		}
+		return routine.Names()
+	})
+}
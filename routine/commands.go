@@ -0,0 +1,77 @@
+package routine
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/guard"
+	"go.codycody31.dev/support/stats"
+)
+
+func ListRoutines(c *cli.Context) error {
+	routines, err := List()
+	if err != nil {
+		return err
+	}
+
+	if len(routines) == 0 {
+		fmt.Printf("No routines found in %s\n", strings.Join(Dirs(), ", "))
+		return nil
+	}
+
+	fmt.Println("Routines:")
+	for _, r := range routines {
+		fmt.Printf("  %s: %s (%s)\n", r.Name, r.Description, r.Path)
+	}
+	return nil
+}
+
+func RunRoutine(c *cli.Context) error {
+	name := c.Args().First()
+	if name == "" {
+		return errs.Usage("a routine name is required")
+	}
+	if c.Args().Len() > 1 {
+		return errs.Usage("unexpected arguments %q, flags must come before the routine name", c.Args().Tail())
+	}
+
+	r, err := Find(name)
+	if err != nil {
+		return err
+	}
+
+	vars := make(map[string]string)
+	for _, v := range c.StringSlice("var") {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok {
+			return errs.Usage("invalid variable %q, expected key=value", v)
+		}
+		vars[key] = value
+	}
+
+	opts := Options{
+		Vars:   vars,
+		DryRun: guard.DryRun(c),
+	}
+	if opts.DryRun {
+		return r.Run(c.Context, c.App, opts)
+	}
+
+	start := time.Now()
+	err = r.Run(c.Context, c.App, opts)
+	stats.RecordRoutine(r.Name, start, err)
+	return err
+}
+
+// Names returns the names of the routines, for completion.
+func Names() []string {
+	routines, _ := List()
+	names := make([]string, len(routines))
+	for i, r := range routines {
+		names[i] = r.Name
+	}
+	return names
+}
@@ -0,0 +1,191 @@
+// Package routine runs routines: YAML workflows made of steps, each running
+// a command of support or of a plugin.
+package routine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Routine is a workflow read from a YAML file.
+type Routine struct {
+	Name        string            `yaml:"name"`
+	Description string            `yaml:"description"`
+	Vars        map[string]string `yaml:"vars"`
+	Steps       []*Step           `yaml:"steps"`
+	// OnFailure runs after a step failed and the routine stopped.
+	OnFailure []*Step `yaml:"on_failure"`
+
+	// Path is the file the routine was read from.
+	Path string `yaml:"-"`
+}
+
+// Step runs a single command. Run, If and every element of Args are Go
+// templates, see Data for the values available to them.
+type Step struct {
+	ID   string `yaml:"id"`
+	Name string `yaml:"name"`
+	// Run is the command line, without the name of the program, e.g.
+	// "caprover delete --regex '^pr-'".
+	Run string `yaml:"run"`
+	// Args is an alternative to Run listing the words of the command line.
+	Args []string `yaml:"args"`
+	// If skips the step unless it renders to "true". Without it a step
+	// runs whenever it is reached: the routine stops at the first failing
+	// step, unless that step has ContinueOnError, so that the steps after
+	// it also run after a failure.
+	If              string `yaml:"if"`
+	ContinueOnError bool   `yaml:"continue_on_error"`
+	// OnFailure runs when this step fails, before the handlers of the
+	// routine.
+	OnFailure []*Step `yaml:"on_failure"`
+}
+
+func (s *Step) title() string {
+	switch {
+	case s.Name != "":
+		return s.Name
+	case s.Run != "":
+		return s.Run
+	default:
+		return strings.Join(s.Args, " ")
+	}
+}
+
+// Dirs returns the directories routines are read from, by precedence: the
+// .support/routines directories of the current directory and its parents,
+// then ~/.support/routines.
+func Dirs() []string {
+	var dirs []string
+	if wd, err := os.Getwd(); err == nil {
+		for dir := wd; ; dir = filepath.Dir(dir) {
+			local := filepath.Join(dir, ".support", "routines")
+			if info, err := os.Stat(local); err == nil && info.IsDir() {
+				dirs = append(dirs, local)
+			}
+			if filepath.Dir(dir) == dir {
+				break
+			}
+		}
+	}
+
+	userDir := filepath.Join(config.SupportDir(), "routines")
+	for _, dir := range dirs {
+		if dir == userDir {
+			return dirs
+		}
+	}
+	return append(dirs, userDir)
+}
+
+// List returns the routines of all directories, sorted by name. A routine
+// hides those of the same name in directories of lower precedence. Files
+// that cannot be read are skipped with a warning, so that one broken
+// routine does not hide the others.
+func List() ([]*Routine, error) {
+	seen := make(map[string]bool)
+	var routines []*Routine
+
+	for _, dir := range Dirs() {
+		for _, pattern := range []string{"*.yaml", "*.yml"} {
+			paths, _ := filepath.Glob(filepath.Join(dir, pattern))
+			for _, path := range paths {
+				r, err := Load(path)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: skipping routine: %v\n", err)
+					continue
+				}
+				if seen[r.Name] {
+					continue
+				}
+				seen[r.Name] = true
+				routines = append(routines, r)
+			}
+		}
+	}
+
+	sort.Slice(routines, func(i, j int) bool {
+		return routines[i].Name < routines[j].Name
+	})
+	return routines, nil
+}
+
+// Find returns the routine called name.
+func Find(name string) (*Routine, error) {
+	routines, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range routines {
+		if r.Name == name {
+			return r, nil
+		}
+	}
+	return nil, errs.NotFound("routine %s not found in %s", name, strings.Join(Dirs(), ", "))
+}
+
+// Load reads the routine of a YAML file. The name of the routine defaults
+// to the name of the file.
+func Load(path string) (*Routine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errs.Config("failed to read routine %s: %v", path, err)
+	}
+
+	r := &Routine{}
+	if err := yaml.UnmarshalStrict(data, r); err != nil {
+		return nil, errs.Config("failed to parse routine %s: %v", path, err)
+	}
+	r.Path = path
+
+	if r.Name == "" {
+		r.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := r.validate(); err != nil {
+		return nil, errs.Config("invalid routine %s: %v", path, err)
+	}
+	return r, nil
+}
+
+func (r *Routine) validate() error {
+	if len(r.Steps) == 0 {
+		return fmt.Errorf("no steps")
+	}
+
+	ids := make(map[string]bool)
+	var check func(steps []*Step) error
+	check = func(steps []*Step) error {
+		for i, step := range steps {
+			if step.Run == "" && len(step.Args) == 0 {
+				return fmt.Errorf("step %d (%s) has neither run nor args", i+1, step.ID)
+			}
+			if step.Run != "" && len(step.Args) > 0 {
+				return fmt.Errorf("step %d (%s) has both run and args", i+1, step.ID)
+			}
+			if step.ID != "" {
+				if ids[step.ID] {
+					return fmt.Errorf("duplicate step id %s", step.ID)
+				}
+				ids[step.ID] = true
+			}
+			if err := check(step.OnFailure); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := check(r.Steps); err != nil {
+		return err
+	}
+	return check(r.OnFailure)
+}
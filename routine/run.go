@@ -0,0 +1,352 @@
+package routine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/runner"
+	"go.codycody31.dev/support/shlex"
+)
+
+// Status of a step.
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+	StatusSkipped = "skipped"
+)
+
+// StepResult is the outcome of a step.
+type StepResult struct {
+	ID       string
+	Status   string
+	ExitCode int
+	// Output is the standard output of the command, without the trailing
+	// newline.
+	Output   string
+	Duration time.Duration
+}
+
+// Data is the value templates are rendered with.
+type Data struct {
+	Vars  map[string]string
+	Env   map[string]string
+	Steps map[string]*StepResult
+	// Failed is the step that failed, set for on_failure handlers.
+	Failed *StepResult
+}
+
+// Options controls a run of a routine.
+type Options struct {
+	// Vars override the variables of the routine.
+	Vars map[string]string
+	// DryRun prints the steps instead of running them.
+	DryRun bool
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+var funcs = template.FuncMap{
+	"env":      os.Getenv,
+	"trim":     strings.TrimSpace,
+	"lower":    strings.ToLower,
+	"upper":    strings.ToUpper,
+	"contains": strings.Contains,
+	"join":     strings.Join,
+	"lines": func(s string) []string {
+		return strings.Split(strings.TrimRight(s, "\n"), "\n")
+	},
+}
+
+// executor holds the state of a run.
+type executor struct {
+	routine *Routine
+	app     *cli.App
+	opts    Options
+	data    *Data
+}
+
+// Run runs the routine with the commands of app. It stops at the first
+// failing step without continue_on_error, runs the on_failure handlers and
+// returns an error of the kind matching the exit code of the step.
+func (r *Routine) Run(ctx context.Context, app *cli.App, opts Options) error {
+	if opts.Stdout == nil {
+		opts.Stdout = os.Stdout
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = os.Stderr
+	}
+
+	e := &executor{
+		routine: r,
+		app:     app,
+		opts:    opts,
+		data: &Data{
+			Vars:  make(map[string]string),
+			Env:   environment(),
+			Steps: make(map[string]*StepResult),
+		},
+	}
+
+	for name, value := range r.Vars {
+		rendered, err := e.render(value)
+		if err != nil {
+			return errs.Config("invalid variable %s: %v", name, err)
+		}
+		e.data.Vars[name] = rendered
+	}
+	for name, value := range opts.Vars {
+		e.data.Vars[name] = value
+	}
+
+	if opts.DryRun {
+		fmt.Fprintf(opts.Stdout, "Routine %s (%s)\n", r.Name, r.Path)
+	}
+
+	for i, step := range r.Steps {
+		id := stepID(step, i)
+		if err := ctx.Err(); err != nil {
+			return e.stopped(id, i, err)
+		}
+
+		result, err := e.runStep(ctx, step, id, fmt.Sprintf("[%d/%d]", i+1, len(r.Steps)))
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			// The step was interrupted, handlers would be too
+			return e.stopped(id, i, err)
+		}
+		if result.Status != StatusFailure {
+			continue
+		}
+
+		e.runHandlers(ctx, step.OnFailure, result)
+		if step.ContinueOnError {
+			continue
+		}
+
+		e.runHandlers(ctx, r.OnFailure, result)
+		return errs.New(errs.KindFromExitCode(result.ExitCode), "routine %s failed at step %s (exit code %d)", r.Name, result.ID, result.ExitCode)
+	}
+
+	if opts.DryRun {
+		e.printHandlers(r.OnFailure, "routine")
+	}
+	return ctx.Err()
+}
+
+// stopped reports a run cancelled or timed out at the step id, the
+// completed steps being the first done.
+func (e *executor) stopped(id string, done int, err error) error {
+	fmt.Fprintf(e.opts.Stderr, "Routine %s stopped at step %s, %d of %d steps completed\n", e.routine.Name, id, done, len(e.routine.Steps))
+	return fmt.Errorf("routine %s stopped: %w", e.routine.Name, err)
+}
+
+func stepID(step *Step, index int) string {
+	if step.ID != "" {
+		return step.ID
+	}
+	return "step" + strconv.Itoa(index+1)
+}
+
+// runStep runs a step, or prints it in dry-run mode. The returned error
+// reports a problem with the routine itself, such as an invalid template.
+func (e *executor) runStep(ctx context.Context, step *Step, id, label string) (*StepResult, error) {
+	result := &StepResult{ID: id, Status: StatusSkipped}
+	e.data.Steps[id] = result
+
+	if step.If != "" {
+		condition, err := e.render(step.If)
+		if err != nil {
+			return nil, errs.Config("invalid condition of step %s: %v", id, err)
+		}
+		if !strings.EqualFold(strings.TrimSpace(condition), "true") {
+			fmt.Fprintf(e.opts.Stdout, "==> %s %s (skipped)\n", label, step.title())
+			return result, nil
+		}
+	}
+
+	args, err := e.args(step)
+	if err != nil {
+		return nil, errs.Config("invalid command of step %s: %v", id, err)
+	}
+	if err := e.checkCommand(args); err != nil {
+		return nil, errs.Config("invalid command of step %s: %v", id, err)
+	}
+
+	fmt.Fprintf(e.opts.Stdout, "==> %s %s\n", label, step.title())
+
+	if e.opts.DryRun {
+		fmt.Fprintf(e.opts.Stdout, "    %s %s\n", e.app.Name, quote(args))
+		result.Status = StatusSuccess
+		result.Output = "<output of " + id + ">"
+		e.printHandlers(step.OnFailure, "step "+id)
+		return result, nil
+	}
+
+	var output bytes.Buffer
+	res, err := runner.Run(ctx, args, os.Stdin, io.MultiWriter(e.opts.Stdout, &output), e.opts.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run step %s: %v", id, err)
+	}
+
+	result.ExitCode = res.ExitCode
+	result.Duration = res.Duration
+	result.Output = strings.TrimRight(output.String(), "\n")
+	result.Status = StatusSuccess
+	if res.ExitCode != 0 {
+		result.Status = StatusFailure
+		fmt.Fprintf(e.opts.Stderr, "Step %s failed with exit code %d\n", id, res.ExitCode)
+	}
+	return result, nil
+}
+
+// runHandlers runs on_failure steps after failed failed. Failing handlers
+// are reported but do not stop the others.
+func (e *executor) runHandlers(ctx context.Context, handlers []*Step, failed *StepResult) {
+	if len(handlers) == 0 || e.opts.DryRun {
+		return
+	}
+
+	e.data.Failed = failed
+	defer func() { e.data.Failed = nil }()
+
+	for i, handler := range handlers {
+		id := handler.ID
+		if id == "" {
+			id = failed.ID + "-on-failure" + strconv.Itoa(i+1)
+		}
+		if _, err := e.runStep(ctx, handler, id, fmt.Sprintf("[on failure %d/%d]", i+1, len(handlers))); err != nil {
+			fmt.Fprintf(e.opts.Stderr, "Error: %v\n", err)
+		}
+	}
+}
+
+// printHandlers prints the on_failure steps in dry-run mode.
+func (e *executor) printHandlers(handlers []*Step, owner string) {
+	if len(handlers) == 0 {
+		return
+	}
+
+	e.data.Failed = &StepResult{ID: "<failed step>", Status: StatusFailure, ExitCode: 1}
+	defer func() { e.data.Failed = nil }()
+
+	fmt.Fprintf(e.opts.Stdout, "    on failure of %s:\n", owner)
+	for _, handler := range handlers {
+		args, err := e.args(handler)
+		if err != nil {
+			fmt.Fprintf(e.opts.Stdout, "      %s (invalid: %v)\n", handler.title(), err)
+			continue
+		}
+		fmt.Fprintf(e.opts.Stdout, "      %s %s\n", e.app.Name, quote(args))
+	}
+}
+
+// args returns the rendered words of the command line of step.
+func (e *executor) args(step *Step) ([]string, error) {
+	words := step.Args
+	if step.Run != "" {
+		var err error
+		words, err = splitTemplate(step.Run)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	args := make([]string, len(words))
+	for i, word := range words {
+		rendered, err := e.render(word)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = rendered
+	}
+	return args, nil
+}
+
+// checkCommand verifies that args start with a command of the app or an
+// alias.
+func (e *executor) checkCommand(args []string) error {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if e.app.Command(arg) == nil {
+			if _, isAlias := config.GetConfig().Aliases[arg]; !isAlias {
+				return fmt.Errorf("unknown command %q", arg)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("no command")
+}
+
+func (e *executor) render(text string) (string, error) {
+	tmpl, err := template.New("").Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, e.data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+var (
+	templateAction = regexp.MustCompile(`\{\{.*?\}\}`)
+	maskedAction   = regexp.MustCompile("\x00(\\d+)\x00")
+)
+
+// splitTemplate splits a command line into words, keeping template actions
+// such as {{ .Vars.name }} whole even though they contain spaces.
+func splitTemplate(line string) ([]string, error) {
+	var actions []string
+	masked := templateAction.ReplaceAllStringFunc(line, func(action string) string {
+		actions = append(actions, action)
+		return fmt.Sprintf("\x00%d\x00", len(actions)-1)
+	})
+
+	words, err := shlex.Split(masked)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, word := range words {
+		words[i] = maskedAction.ReplaceAllStringFunc(word, func(match string) string {
+			n, _ := strconv.Atoi(strings.Trim(match, "\x00"))
+			return actions[n]
+		})
+	}
+	return words, nil
+}
+
+func quote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shlex.Quote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func environment() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if i := strings.Index(kv, "="); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	return env
+}
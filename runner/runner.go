@@ -0,0 +1,86 @@
+// Package runner runs support commands in a child process of the current
+// binary, so that their output can be captured and several can run at once.
+package runner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/guard"
+	"go.codycody31.dev/support/host"
+)
+
+// Result is the outcome of a command.
+type Result struct {
+	ExitCode int
+	Duration time.Duration
+}
+
+// Err returns an error of the kind matching the exit code, or nil if the
+// command succeeded.
+func (r *Result) Err() error {
+	if r.ExitCode == 0 {
+		return nil
+	}
+	return errs.New(errs.KindFromExitCode(r.ExitCode), "command exited with code %d", r.ExitCode)
+}
+
+// Command returns the command running support with args, such as
+// ["caprover", "list"], with the current profile, --yes and --dry-run. Run
+// it with host.Run so that it is interrupted rather than killed when its
+// context is cancelled.
+func Command(args ...string) (*exec.Cmd, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(executable, args...)
+	cmd.Env = append(os.Environ(), "SUPPORT_PROFILE="+config.Profile())
+	if guard.AssumeYes() {
+		cmd.Env = append(cmd.Env, "SUPPORT_YES=true")
+	}
+	if guard.GlobalDryRun() {
+		cmd.Env = append(cmd.Env, "SUPPORT_DRY_RUN=true")
+	}
+	return cmd, nil
+}
+
+// Run runs support with args, writing its output to stdout and stderr. An
+// error is only returned if the command could not be started; a failing
+// command is reported by the exit code of the result. When ctx is cancelled
+// the command is interrupted, and reported as timed out or canceled.
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) (*Result, error) {
+	cmd, err := Command(args...)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	start := time.Now()
+	err = host.Run(ctx, cmd)
+	result := &Result{Duration: time.Since(start)}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err != nil && err == ctx.Err():
+		result.ExitCode = errs.KindOf(err).ExitCode()
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+		if result.ExitCode < 0 {
+			// Killed by a signal it did not send itself
+			result.ExitCode = errs.KindUnknown.ExitCode()
+		}
+	case err != nil:
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"go.codycody31.dev/support/completion"
+	"go.codycody31.dev/support/scheduler"
+
+	"github.com/urfave/cli/v2"
+)
+
+var SchedulerCommand = &cli.Command{
+	Name:  "scheduler",
+	Usage: "Run routines and commands on a schedule",
+	Description: `Jobs are read from the scheduler section of config.yaml:
+
+     scheduler:
+       jobs:
+         - name: cleanup-previews
+           schedule: "0 3 * * *"
+           routine: cleanup-previews
+           jitter: 5m
+           timeout: 30m
+           catch_up: once
+         - name: containers
+           schedule: "@every 10m"
+           command: docker list
+
+   catch_up decides what happens to runs missed while the scheduler was
+   stopped: skip (default), once or all.`,
+	Subcommands: []*cli.Command{
+		{
+			Name:   "start",
+			Usage:  "Run the scheduler in the foreground",
+			Action: scheduler.StartScheduler,
+		},
+		{
+			Name:   "list",
+			Usage:  "List scheduled jobs",
+			Action: scheduler.ListJobs,
+		},
+		{
+			Name:   "status",
+			Usage:  "Show the status of the running scheduler",
+			Action: scheduler.ShowStatus,
+		},
+		{
+			Name:      "trigger",
+			Usage:     "Run a job now",
+			ArgsUsage: "<job>",
+			Action:    scheduler.TriggerJob,
+		},
+	},
+}
+
+func init() {
+	completion.Register("scheduler trigger", func(command []string, flag, prefix string) []string {
+		if flag != "" {
+			return nil
+		}
+		return scheduler.JobNames()
+	})
+}
@@ -0,0 +1,69 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"go.codycody31.dev/support/errs"
+)
+
+// client talks to the daemon over its unix socket.
+type client struct {
+	http *http.Client
+}
+
+func newClient() *client {
+	socket := socketPath()
+	return &client{
+		http: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// do sends a request to the daemon and decodes the JSON response into out.
+func (c *client) do(method, path string, out interface{}) error {
+	req, err := http.NewRequest(method, "http://scheduler"+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return errs.Network("scheduler is not running (socket %s): %v", socketPath(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var body struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return errs.NotFound("%s", body.Error)
+		case http.StatusConflict:
+			return errs.Usage("%s", body.Error)
+		default:
+			return errs.RemoteStatus(resp.StatusCode, "scheduler: %s", body.Error)
+		}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to read scheduler response: %v", err)
+	}
+	return nil
+}
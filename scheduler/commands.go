@@ -0,0 +1,116 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/errs"
+)
+
+func StartScheduler(c *cli.Context) error {
+	return Start(c.Context)
+}
+
+func ListJobs(c *cli.Context) error {
+	var statuses []JobStatus
+	err := newClient().do("GET", "/jobs", &statuses)
+	if err != nil {
+		// Without a daemon, show the configuration and the last known state
+		statuses, err = offlineStatuses()
+		if err != nil {
+			return err
+		}
+		fmt.Println("Scheduler is not running")
+	}
+
+	if len(statuses) == 0 {
+		fmt.Println("No scheduled jobs")
+		return nil
+	}
+
+	fmt.Println("Jobs:")
+	for _, s := range statuses {
+		state := s.LastStatus
+		if s.Running {
+			state = "running"
+		}
+		if state == "" {
+			state = "never run"
+		}
+		fmt.Printf("  %s: %s [%s] next=%s last=%s (%s)\n", s.Name, s.Target, s.Schedule, formatTime(s.NextRun), formatTime(s.LastRun), state)
+	}
+	return nil
+}
+
+func offlineStatuses() ([]JobStatus, error) {
+	jobs, err := loadJobs()
+	if err != nil {
+		return nil, err
+	}
+	state, err := loadState()
+	if err != nil {
+		return nil, errs.Config("failed to read scheduler state: %v", err)
+	}
+
+	statuses := make([]JobStatus, len(jobs))
+	for i, j := range jobs {
+		statuses[i] = JobStatus{
+			Name:     j.Name,
+			Schedule: j.Schedule,
+			Target:   j.target(),
+			CatchUp:  j.CatchUp,
+		}
+		if s, exists := state[j.Name]; exists {
+			statuses[i].JobState = *s
+		}
+		statuses[i].NextRun = j.schedule.Next(time.Now())
+	}
+	return statuses, nil
+}
+
+func ShowStatus(c *cli.Context) error {
+	var status DaemonStatus
+	if err := newClient().do("GET", "/status", &status); err != nil {
+		return err
+	}
+
+	fmt.Printf("Scheduler running (pid %d) since %s\n", status.PID, status.Started.Local().Format(time.RFC3339))
+	fmt.Printf("Socket: %s\n", status.Socket)
+	fmt.Printf("Jobs: %d\n", status.Jobs)
+	if len(status.Running) > 0 {
+		fmt.Printf("Running: %v\n", status.Running)
+	}
+	return nil
+}
+
+func TriggerJob(c *cli.Context) error {
+	name := c.Args().First()
+	if name == "" {
+		return errs.Usage("a job name is required")
+	}
+
+	if err := newClient().do("POST", "/jobs/"+name+"/trigger", nil); err != nil {
+		return err
+	}
+
+	fmt.Printf("Job %s triggered\n", name)
+	return nil
+}
+
+// JobNames returns the names of the configured jobs, for completion.
+func JobNames() []string {
+	jobs, _ := loadJobs()
+	names := make([]string, len(jobs))
+	for i, j := range jobs {
+		names[i] = j.Name
+	}
+	return names
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
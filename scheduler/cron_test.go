@@ -0,0 +1,277 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		spec  string
+		valid bool
+	}{
+		{"* * * * *", true},
+		{"*/15 * * * *", true},
+		{"0 9-17 * * mon-fri", true},
+		{"0 0 1,15 * *", true},
+		{"30 2 * jan,jul sun", true},
+		{"0-30/5 * * * *", true},
+		{"5/20 * * * *", true},
+		{"0 0 * * 7", true},
+		{"@daily", true},
+		{"@every 90s", true},
+		{" @hourly ", true},
+
+		{"", false},
+		{"* * * *", false},
+		{"* * * * * *", false},
+		{"60 * * * *", false},
+		{"* 24 * * *", false},
+		{"* * 0 * *", false},
+		{"* * 32 * *", false},
+		{"* * * 13 *", false},
+		{"* * * * 8", false},
+		{"*/0 * * * *", false},
+		{"30-10 * * * *", false},
+		{"-5 * * * *", false},
+		{"* * * foo *", false},
+		{"@every 500ms", false},
+		{"@every soon", false},
+		{"@weekly 1", false},
+	}
+
+	for _, test := range tests {
+		_, err := ParseSchedule(test.spec)
+		if test.valid && err != nil {
+			t.Errorf("ParseSchedule(%q) failed: %v", test.spec, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded, expected an error", test.spec)
+		}
+	}
+}
+
+func TestParseField(t *testing.T) {
+	tests := []struct {
+		expr   string
+		f      field
+		values []int
+	}{
+		{"*/15", minuteField, []int{0, 15, 30, 45}},
+		{"5/20", minuteField, []int{5, 25, 45}},
+		{"0-30/10", minuteField, []int{0, 10, 20, 30}},
+		{"1,3,5-7", hourField, []int{1, 3, 5, 6, 7}},
+		{"jan,MAR,dec", monthField, []int{1, 3, 12}},
+		{"mon-fri", dowField, []int{1, 2, 3, 4, 5}},
+		{"*/10", domField, []int{1, 11, 21, 31}},
+	}
+
+	for _, test := range tests {
+		bits, err := parseField(test.expr, test.f)
+		if err != nil {
+			t.Errorf("parseField(%q) failed: %v", test.expr, err)
+			continue
+		}
+		var expected uint64
+		for _, v := range test.values {
+			expected |= 1 << uint(v)
+		}
+		if bits != expected {
+			t.Errorf("parseField(%q) = %b, expected %b", test.expr, bits, expected)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	utc := time.UTC
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		next []time.Time
+	}{
+		{
+			name: "every minute is strictly after",
+			spec: "* * * * *",
+			from: time.Date(2026, 1, 1, 10, 0, 0, 0, utc),
+			next: []time.Time{
+				time.Date(2026, 1, 1, 10, 1, 0, 0, utc),
+				time.Date(2026, 1, 1, 10, 2, 0, 0, utc),
+			},
+		},
+		{
+			name: "seconds are truncated",
+			spec: "*/15 * * * *",
+			from: time.Date(2026, 1, 1, 10, 14, 59, 0, utc),
+			next: []time.Time{
+				time.Date(2026, 1, 1, 10, 15, 0, 0, utc),
+				time.Date(2026, 1, 1, 10, 30, 0, 0, utc),
+			},
+		},
+		{
+			name: "hour rolls over the day",
+			spec: "30 23 * * *",
+			from: time.Date(2026, 1, 1, 23, 30, 0, 0, utc),
+			next: []time.Time{
+				time.Date(2026, 1, 2, 23, 30, 0, 0, utc),
+			},
+		},
+		{
+			name: "year rolls over",
+			spec: "@yearly",
+			from: time.Date(2026, 6, 1, 0, 0, 0, 0, utc),
+			next: []time.Time{
+				time.Date(2027, 1, 1, 0, 0, 0, 0, utc),
+				time.Date(2028, 1, 1, 0, 0, 0, 0, utc),
+			},
+		},
+		{
+			name: "weekdays skip the weekend",
+			spec: "0 9 * * mon-fri",
+			// Friday
+			from: time.Date(2026, 1, 2, 9, 0, 0, 0, utc),
+			next: []time.Time{
+				time.Date(2026, 1, 5, 9, 0, 0, 0, utc),
+				time.Date(2026, 1, 6, 9, 0, 0, 0, utc),
+			},
+		},
+		{
+			name: "sunday as 7",
+			spec: "0 0 * * 7",
+			from: time.Date(2026, 1, 1, 0, 0, 0, 0, utc),
+			next: []time.Time{
+				time.Date(2026, 1, 4, 0, 0, 0, 0, utc),
+			},
+		},
+		{
+			name: "day of month and day of week match either",
+			spec: "0 0 13 * fri",
+			from: time.Date(2026, 2, 1, 0, 0, 0, 0, utc),
+			next: []time.Time{
+				time.Date(2026, 2, 6, 0, 0, 0, 0, utc),
+				time.Date(2026, 2, 13, 0, 0, 0, 0, utc),
+				time.Date(2026, 2, 20, 0, 0, 0, 0, utc),
+				time.Date(2026, 2, 27, 0, 0, 0, 0, utc),
+				time.Date(2026, 3, 6, 0, 0, 0, 0, utc),
+				time.Date(2026, 3, 13, 0, 0, 0, 0, utc),
+			},
+		},
+		{
+			name: "a star day of month leaves the day of week",
+			spec: "0 0 * * fri",
+			from: time.Date(2026, 2, 1, 0, 0, 0, 0, utc),
+			next: []time.Time{
+				time.Date(2026, 2, 6, 0, 0, 0, 0, utc),
+				time.Date(2026, 2, 13, 0, 0, 0, 0, utc),
+			},
+		},
+		{
+			name: "a stepped star day of week restricts the day of month",
+			spec: "0 0 1-7 * */2",
+			// Tuesdays, Thursdays, Saturdays and Sundays of the first week
+			from: time.Date(2026, 2, 28, 0, 0, 0, 0, utc),
+			next: []time.Time{
+				time.Date(2026, 3, 1, 0, 0, 0, 0, utc),
+				time.Date(2026, 3, 3, 0, 0, 0, 0, utc),
+				time.Date(2026, 3, 5, 0, 0, 0, 0, utc),
+				time.Date(2026, 3, 7, 0, 0, 0, 0, utc),
+				time.Date(2026, 4, 2, 0, 0, 0, 0, utc),
+			},
+		},
+		{
+			name: "31st skips short months",
+			spec: "0 0 31 * *",
+			from: time.Date(2026, 1, 31, 0, 0, 0, 0, utc),
+			next: []time.Time{
+				time.Date(2026, 3, 31, 0, 0, 0, 0, utc),
+				time.Date(2026, 5, 31, 0, 0, 0, 0, utc),
+			},
+		},
+		{
+			name: "february 29th waits for a leap year",
+			spec: "0 12 29 feb *",
+			from: time.Date(2026, 1, 1, 0, 0, 0, 0, utc),
+			next: []time.Time{
+				time.Date(2028, 2, 29, 12, 0, 0, 0, utc),
+			},
+		},
+		{
+			name: "never matching",
+			spec: "0 0 30 feb *",
+			from: time.Date(2026, 1, 1, 0, 0, 0, 0, utc),
+			next: []time.Time{{}},
+		},
+		{
+			name: "clocks going forward run the skipped time after the change",
+			spec: "30 2 * * *",
+			from: time.Date(2026, 3, 7, 2, 30, 0, 0, newYork),
+			next: []time.Time{
+				time.Date(2026, 3, 8, 7, 30, 0, 0, utc),
+				time.Date(2026, 3, 9, 2, 30, 0, 0, newYork),
+			},
+		},
+		{
+			name: "clocks going forward run an hourly job once",
+			spec: "0 * * * *",
+			from: time.Date(2026, 3, 8, 1, 0, 0, 0, newYork),
+			next: []time.Time{
+				// 03:00 EDT, for both 02:00 and 03:00
+				time.Date(2026, 3, 8, 7, 0, 0, 0, utc),
+				time.Date(2026, 3, 8, 8, 0, 0, 0, utc),
+			},
+		},
+		{
+			name: "clocks going back run a time of the repeated hour once",
+			spec: "30 1 * * *",
+			from: time.Date(2026, 10, 31, 1, 30, 0, 0, newYork),
+			next: []time.Time{
+				// 01:30 EDT, the first of the two
+				time.Date(2026, 11, 1, 5, 30, 0, 0, utc),
+				time.Date(2026, 11, 2, 1, 30, 0, 0, newYork),
+			},
+		},
+		{
+			name: "clocks going back keep the wall clock of daily jobs",
+			spec: "0 9 * * *",
+			from: time.Date(2026, 10, 31, 9, 0, 0, 0, newYork),
+			next: []time.Time{
+				time.Date(2026, 11, 1, 9, 0, 0, 0, newYork),
+				time.Date(2026, 11, 2, 9, 0, 0, 0, newYork),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s, err := ParseSchedule(test.spec)
+		if err != nil {
+			t.Fatalf("%s: ParseSchedule(%q) failed: %v", test.name, test.spec, err)
+		}
+		from := test.from
+		for i, expected := range test.next {
+			next := s.Next(from)
+			if !next.Equal(expected) {
+				t.Errorf("%s: run %d of %q after %s is %s, expected %s", test.name, i+1, test.spec, from, next, expected)
+				break
+			}
+			from = next
+		}
+	}
+}
+
+func TestNextEvery(t *testing.T) {
+	s, err := ParseSchedule("@every 90s")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from := time.Date(2026, 1, 1, 10, 0, 0, 500, time.UTC)
+	expected := time.Date(2026, 1, 1, 10, 1, 30, 0, time.UTC)
+	if next := s.Next(from); !next.Equal(expected) {
+		t.Errorf("Next(%s) = %s, expected %s", from, next, expected)
+	}
+}
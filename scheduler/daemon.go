@@ -0,0 +1,367 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/runner"
+)
+
+// JobStatus is the description of a job returned by the daemon.
+type JobStatus struct {
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"`
+	Target   string `json:"target"`
+	CatchUp  string `json:"catch_up"`
+	Running  bool   `json:"running"`
+	JobState
+}
+
+// DaemonStatus is the status of the daemon.
+type DaemonStatus struct {
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+	Socket  string    `json:"socket"`
+	Jobs    int       `json:"jobs"`
+	Running []string  `json:"running"`
+}
+
+type daemon struct {
+	jobs    []*job
+	started time.Time
+	socket  string
+
+	mu      sync.Mutex
+	state   map[string]*JobState
+	running map[string]bool
+	wg      sync.WaitGroup
+}
+
+// run runs the daemon until ctx is cancelled.
+func (d *daemon) run(ctx context.Context) error {
+	listener, err := listen(d.socket)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Handler: d.handler(ctx)}
+	go server.Serve(listener)
+	defer os.Remove(d.socket)
+
+	log.Printf("scheduler started with %d job(s), listening on %s", len(d.jobs), d.socket)
+
+	for _, j := range d.jobs {
+		d.wg.Add(1)
+		go func(j *job) {
+			defer d.wg.Done()
+			d.catchUp(ctx, j)
+			d.loop(ctx, j)
+		}(j)
+	}
+
+	<-ctx.Done()
+	log.Printf("scheduler stopping")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+
+	d.wg.Wait()
+	return nil
+}
+
+// listen listens on the unix socket, replacing a stale socket left by a
+// daemon that did not exit cleanly.
+func listen(socket string) (net.Listener, error) {
+	if _, err := os.Stat(socket); err == nil {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			conn.Close()
+			return nil, errs.Usage("a scheduler is already running on %s", socket)
+		}
+		os.Remove(socket)
+	}
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	os.Chmod(socket, 0600)
+	return listener, nil
+}
+
+// loop runs the job at each of its scheduled times.
+func (d *daemon) loop(ctx context.Context, j *job) {
+	for {
+		next := j.schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("job %s: schedule %q never matches", j.Name, j.Schedule)
+			return
+		}
+		d.updateState(j, func(s *JobState) { s.NextRun = next })
+
+		delay := time.Until(next) + jitterDelay(j.Jitter)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		d.execute(ctx, j, next)
+	}
+}
+
+// catchUp runs the job for the schedule slots missed since its last run,
+// following its catch-up policy.
+func (d *daemon) catchUp(ctx context.Context, j *job) {
+	d.mu.Lock()
+	last := d.stateOf(j.Name).LastScheduled
+	d.mu.Unlock()
+
+	if last.IsZero() || j.CatchUp == CatchUpSkip {
+		return
+	}
+
+	missed := missedRuns(j.schedule, last, time.Now())
+	if len(missed) == 0 {
+		return
+	}
+
+	log.Printf("job %s: %d run(s) missed since %s", j.Name, len(missed), last.Format(time.RFC3339))
+	for _, slot := range catchUpRuns(j.CatchUp, missed) {
+		if ctx.Err() != nil {
+			return
+		}
+		d.execute(ctx, j, slot)
+	}
+}
+
+// jitterDelay returns a random delay shorter than jitter, or 0.
+func jitterDelay(jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(jitter)))
+}
+
+// missedRuns returns the slots of s after last and before now, at most
+// maxCatchUpRuns of them.
+func missedRuns(s Schedule, last, now time.Time) []time.Time {
+	var missed []time.Time
+	for t := s.Next(last); !t.IsZero() && t.Before(now) && len(missed) < maxCatchUpRuns; t = s.Next(t) {
+		missed = append(missed, t)
+	}
+	return missed
+}
+
+// catchUpRuns returns the missed slots run by the catch-up policy.
+func catchUpRuns(policy string, missed []time.Time) []time.Time {
+	switch {
+	case len(missed) == 0 || policy == CatchUpSkip:
+		return nil
+	case policy == CatchUpOnce:
+		return missed[len(missed)-1:]
+	default:
+		return missed
+	}
+}
+
+// execute runs the job for the schedule slot, unless it is already running.
+func (d *daemon) execute(ctx context.Context, j *job, slot time.Time) {
+	d.mu.Lock()
+	if d.running[j.Name] {
+		d.mu.Unlock()
+		log.Printf("job %s: previous run still in progress, skipping", j.Name)
+		return
+	}
+	d.running[j.Name] = true
+	d.mu.Unlock()
+
+	defer func() {
+		d.mu.Lock()
+		delete(d.running, j.Name)
+		d.mu.Unlock()
+	}()
+
+	runCtx := ctx
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+
+	log.Printf("job %s: running %s", j.Name, strings.Join(j.args, " "))
+	start := time.Now()
+	result, err := runner.Run(runCtx, j.args, nil, os.Stdout, os.Stderr)
+
+	status := StatusSuccess
+	exitCode := 0
+	switch {
+	case err != nil:
+		status = StatusFailure
+		exitCode = errs.ExitCode(err)
+		log.Printf("job %s: failed to start: %v", j.Name, err)
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		status = StatusTimeout
+		exitCode = result.ExitCode
+		log.Printf("job %s: timed out after %s", j.Name, j.Timeout)
+	case result.ExitCode != 0:
+		status = StatusFailure
+		exitCode = result.ExitCode
+		log.Printf("job %s: failed with exit code %d", j.Name, exitCode)
+	default:
+		log.Printf("job %s: finished in %s", j.Name, result.Duration.Round(time.Millisecond))
+	}
+
+	d.updateState(j, func(s *JobState) {
+		if !slot.IsZero() {
+			s.LastScheduled = slot
+		}
+		s.LastRun = start
+		s.LastStatus = status
+		s.LastExitCode = exitCode
+		s.LastDuration = time.Since(start).Round(time.Millisecond).String()
+		s.Runs++
+		if status != StatusSuccess {
+			s.Failures++
+		}
+	})
+}
+
+// stateOf returns the state of a job, d.mu must be held.
+func (d *daemon) stateOf(name string) *JobState {
+	s, exists := d.state[name]
+	if !exists {
+		s = &JobState{}
+		d.state[name] = s
+	}
+	return s
+}
+
+func (d *daemon) updateState(j *job, update func(*JobState)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	update(d.stateOf(j.Name))
+	if err := saveState(d.state); err != nil {
+		log.Printf("failed to save scheduler state: %v", err)
+	}
+}
+
+func (d *daemon) jobStatuses() []JobStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	statuses := make([]JobStatus, len(d.jobs))
+	for i, j := range d.jobs {
+		statuses[i] = JobStatus{
+			Name:     j.Name,
+			Schedule: j.Schedule,
+			Target:   j.target(),
+			CatchUp:  j.CatchUp,
+			Running:  d.running[j.Name],
+			JobState: *d.stateOf(j.Name),
+		}
+	}
+	return statuses
+}
+
+func (d *daemon) handler(ctx context.Context) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		status := DaemonStatus{
+			PID:     os.Getpid(),
+			Started: d.started,
+			Socket:  d.socket,
+			Jobs:    len(d.jobs),
+			Running: []string{},
+		}
+		for name := range d.running {
+			status.Running = append(status.Running, name)
+		}
+		d.mu.Unlock()
+
+		writeJSON(w, http.StatusOK, status)
+	})
+
+	mux.HandleFunc("/jobs", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, d.jobStatuses())
+	})
+
+	mux.HandleFunc("/jobs/", func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/trigger")
+		if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/trigger") {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+			return
+		}
+
+		for _, j := range d.jobs {
+			if j.Name != name {
+				continue
+			}
+
+			d.mu.Lock()
+			running := d.running[name]
+			d.mu.Unlock()
+			if running {
+				writeJSON(w, http.StatusConflict, map[string]string{"error": "job " + name + " is already running"})
+				return
+			}
+
+			d.wg.Add(1)
+			go func(j *job) {
+				defer d.wg.Done()
+				d.execute(ctx, j, time.Time{})
+			}(j)
+			writeJSON(w, http.StatusAccepted, map[string]string{"triggered": name})
+			return
+		}
+
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "job " + name + " not found"})
+	})
+
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// Start runs the scheduler in the foreground until it receives SIGINT or
+// SIGTERM.
+func Start(ctx context.Context) error {
+	jobs, err := loadJobs()
+	if err != nil {
+		return err
+	}
+
+	state, err := loadState()
+	if err != nil {
+		return errs.Config("failed to read scheduler state: %v", err)
+	}
+
+	d := &daemon{
+		jobs:    jobs,
+		started: time.Now(),
+		socket:  socketPath(),
+		state:   state,
+		running: make(map[string]bool),
+	}
+	return d.run(ctx)
+}
@@ -0,0 +1,104 @@
+// Package scheduler runs routines and commands on cron schedules from a
+// foreground daemon controlled through a unix socket.
+package scheduler
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/shlex"
+)
+
+// Catch-up policies for runs missed while the scheduler was stopped.
+const (
+	CatchUpSkip = "skip"
+	CatchUpOnce = "once"
+	CatchUpAll  = "all"
+)
+
+// maxCatchUpRuns bounds the runs of the "all" catch-up policy.
+const maxCatchUpRuns = 100
+
+// job is a scheduled job ready to run.
+type job struct {
+	config.ScheduledJob
+	schedule Schedule
+	// args is the command line of support run by the job.
+	args []string
+}
+
+// target describes what the job runs.
+func (j *job) target() string {
+	if j.Routine != "" {
+		return "routine " + j.Routine
+	}
+	return j.Command
+}
+
+// loadJobs returns the enabled jobs of the configuration.
+func loadJobs() ([]*job, error) {
+	var jobs []*job
+	names := make(map[string]bool)
+
+	for _, jobConfig := range config.GetConfig().Scheduler.Jobs {
+		if jobConfig.Disabled {
+			continue
+		}
+
+		j, err := newJob(jobConfig)
+		if err != nil {
+			return nil, errs.Config("invalid scheduled job %s: %v", jobConfig.Name, err)
+		}
+		if names[j.Name] {
+			return nil, errs.Config("duplicate scheduled job %s", j.Name)
+		}
+		names[j.Name] = true
+		jobs = append(jobs, j)
+	}
+	return jobs, nil
+}
+
+func newJob(jobConfig config.ScheduledJob) (*job, error) {
+	if jobConfig.Name == "" {
+		return nil, fmt.Errorf("missing name")
+	}
+
+	schedule, err := ParseSchedule(jobConfig.Schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &job{ScheduledJob: jobConfig, schedule: schedule}
+
+	switch {
+	case j.Routine != "" && j.Command != "":
+		return nil, fmt.Errorf("both routine and command are set")
+	case j.Routine != "":
+		j.args = []string{"routine", "run", j.Routine}
+	case j.Command != "":
+		j.args, err = shlex.Split(j.Command)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("neither routine nor command is set")
+	}
+
+	switch j.CatchUp {
+	case "":
+		j.CatchUp = CatchUpSkip
+	case CatchUpSkip, CatchUpOnce, CatchUpAll:
+	default:
+		return nil, fmt.Errorf("invalid catch_up %q, expected skip, once or all", j.CatchUp)
+	}
+	return j, nil
+}
+
+func socketPath() string {
+	if socket := config.GetConfig().Scheduler.Socket; socket != "" {
+		return socket
+	}
+	return filepath.Join(config.SupportDir(), "scheduler.sock")
+}
@@ -0,0 +1,144 @@
+package scheduler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.codycody31.dev/support/config"
+)
+
+func TestNewJob(t *testing.T) {
+	tests := []struct {
+		name    string
+		job     config.ScheduledJob
+		args    []string
+		catchUp string
+		valid   bool
+	}{
+		{
+			name:    "routine",
+			job:     config.ScheduledJob{Name: "cleanup", Schedule: "@daily", Routine: "cleanup"},
+			args:    []string{"routine", "run", "cleanup"},
+			catchUp: CatchUpSkip,
+			valid:   true,
+		},
+		{
+			name:    "command",
+			job:     config.ScheduledJob{Name: "delete", Schedule: "0 3 * * *", Command: "caprover delete --regex '^pr-'", CatchUp: CatchUpOnce},
+			args:    []string{"caprover", "delete", "--regex", "^pr-"},
+			catchUp: CatchUpOnce,
+			valid:   true,
+		},
+		{
+			name: "missing name",
+			job:  config.ScheduledJob{Schedule: "@daily", Routine: "cleanup"},
+		},
+		{
+			name: "invalid schedule",
+			job:  config.ScheduledJob{Name: "x", Schedule: "0 25 * * *", Routine: "cleanup"},
+		},
+		{
+			name: "routine and command",
+			job:  config.ScheduledJob{Name: "x", Schedule: "@daily", Routine: "cleanup", Command: "plugins list"},
+		},
+		{
+			name: "nothing to run",
+			job:  config.ScheduledJob{Name: "x", Schedule: "@daily"},
+		},
+		{
+			name: "invalid catch-up",
+			job:  config.ScheduledJob{Name: "x", Schedule: "@daily", Routine: "cleanup", CatchUp: "always"},
+		},
+	}
+
+	for _, test := range tests {
+		j, err := newJob(test.job)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%s: newJob succeeded, expected an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: newJob failed: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(j.args, test.args) {
+			t.Errorf("%s: args are %q, expected %q", test.name, j.args, test.args)
+		}
+		if j.CatchUp != test.catchUp {
+			t.Errorf("%s: catch-up is %q, expected %q", test.name, j.CatchUp, test.catchUp)
+		}
+	}
+}
+
+func TestCatchUp(t *testing.T) {
+	s, err := ParseSchedule("0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hour := func(h int) time.Time {
+		return time.Date(2026, 1, 1, h, 0, 0, 0, time.UTC)
+	}
+
+	last := hour(1)
+	now := hour(4).Add(30 * time.Minute)
+	missed := missedRuns(s, last, now)
+	if expected := []time.Time{hour(2), hour(3), hour(4)}; !reflect.DeepEqual(missed, expected) {
+		t.Fatalf("missed runs are %v, expected %v", missed, expected)
+	}
+
+	tests := []struct {
+		policy string
+		runs   []time.Time
+	}{
+		{CatchUpSkip, nil},
+		{CatchUpOnce, []time.Time{hour(4)}},
+		{CatchUpAll, []time.Time{hour(2), hour(3), hour(4)}},
+	}
+	for _, test := range tests {
+		if runs := catchUpRuns(test.policy, missed); !reflect.DeepEqual(runs, test.runs) {
+			t.Errorf("catch-up %s runs %v, expected %v", test.policy, runs, test.runs)
+		}
+	}
+
+	for _, policy := range []string{CatchUpSkip, CatchUpOnce, CatchUpAll} {
+		if runs := catchUpRuns(policy, nil); len(runs) != 0 {
+			t.Errorf("catch-up %s runs %v without missed runs", policy, runs)
+		}
+	}
+}
+
+func TestMissedRunsBound(t *testing.T) {
+	s, err := ParseSchedule("* * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	last := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	missed := missedRuns(s, last, last.AddDate(0, 0, 7))
+	if len(missed) != maxCatchUpRuns {
+		t.Errorf("%d missed runs, expected at most %d", len(missed), maxCatchUpRuns)
+	}
+
+	if missed := missedRuns(s, last, last.Add(30*time.Second)); len(missed) != 0 {
+		t.Errorf("missed runs %v before the next slot", missed)
+	}
+}
+
+func TestJitterDelay(t *testing.T) {
+	if delay := jitterDelay(0); delay != 0 {
+		t.Errorf("jitter delay without jitter is %s", delay)
+	}
+	if delay := jitterDelay(-time.Second); delay != 0 {
+		t.Errorf("jitter delay with a negative jitter is %s", delay)
+	}
+
+	jitter := 100 * time.Millisecond
+	for i := 0; i < 1000; i++ {
+		if delay := jitterDelay(jitter); delay < 0 || delay >= jitter {
+			t.Fatalf("jitter delay %s out of [0, %s)", delay, jitter)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.codycody31.dev/support/config"
+)
+
+// Status of the last run of a job.
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+	StatusTimeout = "timeout"
+)
+
+// JobState is what the scheduler remembers of a job between restarts.
+type JobState struct {
+	// LastScheduled is the schedule slot of the last run, used to find the
+	// runs missed while the scheduler was stopped.
+	LastScheduled time.Time `json:"last_scheduled,omitempty"`
+	LastRun       time.Time `json:"last_run,omitempty"`
+	LastStatus    string    `json:"last_status,omitempty"`
+	LastExitCode  int       `json:"last_exit_code"`
+	LastDuration  string    `json:"last_duration,omitempty"`
+	NextRun       time.Time `json:"next_run,omitempty"`
+	Runs          int       `json:"runs"`
+	Failures      int       `json:"failures"`
+}
+
+func stateFilePath() string {
+	return filepath.Join(config.SupportDir(), "scheduler", "state.json")
+}
+
+// loadState returns the state of the jobs by name.
+func loadState() (map[string]*JobState, error) {
+	state := make(map[string]*JobState)
+
+	data, err := os.ReadFile(stateFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// saveState writes the state file atomically.
+func saveState(state map[string]*JobState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := stateFilePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"go.codycody31.dev/support/update"
+
+	"github.com/urfave/cli/v2"
+)
+
+var SelfUpdateCommand = &cli.Command{
+	Name:  "self-update",
+	Usage: "Update support to the latest release",
+	Description: `Downloads the binary of the release from the GitHub releases of support,
+   or the URL of update.url: a mirror or a local directory laid out as
+
+     <url>/<channel>/VERSION    latest version of the channel
+     <url>/<version>/<asset>    support_<os>_<arch>, SHA256SUMS, SHA256SUMS.sig
+
+   The checksum of the binary and the ed25519 signature of SHA256SUMS are
+   verified, and the enabled plugins are checked to load with the new binary
+   before it replaces the current one.`,
+	Action: update.SelfUpdate,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "channel",
+			Usage: "Release channel: stable or beta",
+			Value: update.ChannelStable,
+		},
+		&cli.StringFlag{
+			Name:  "url",
+			Usage: "GitHub repository, mirror URL or directory to download releases from",
+		},
+		&cli.StringFlag{
+			Name:  "version",
+			Usage: "Install this version instead of the latest",
+		},
+		&cli.BoolFlag{
+			Name:  "check",
+			Usage: "Only report whether an update is available",
+		},
+		&cli.BoolFlag{
+			Name:  "force",
+			Usage: "Reinstall the current version, or install even if plugins are not compatible",
+		},
+		&cli.BoolFlag{
+			Name:  "rollback",
+			Usage: "Restore the binary replaced by the last update",
+		},
+	},
+}
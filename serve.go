@@ -0,0 +1,38 @@
+package main
+
+import (
+	"go.codycody31.dev/support/server"
+
+	"github.com/urfave/cli/v2"
+)
+
+var ServeCommand = &cli.Command{
+	Name:  "serve",
+	Usage: "Expose the commands as a local HTTP API",
+	Description: `Endpoints, all requiring "Authorization: Bearer <token>" when a token is set:
+
+     GET  /v1/commands              list the commands with their flags as JSON schema
+     GET  /v1/commands/<path>       describe a command, e.g. /v1/commands/caprover/list
+     POST /v1/commands/<path>       run a command with {"flags": {...}, "args": [...]}
+
+   Add ?stream=true (or Accept: application/x-ndjson) to POST to receive the
+   output as it is written, followed by the result.`,
+	Action: server.Serve,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "listen",
+			Aliases: []string{"l"},
+			Usage:   "TCP address to listen on",
+			Value:   "127.0.0.1:7070",
+		},
+		&cli.StringFlag{
+			Name:  "socket",
+			Usage: "Listen on this unix socket only instead of TCP",
+		},
+		&cli.StringFlag{
+			Name:    "token",
+			Usage:   "Bearer token required from clients",
+			EnvVars: []string{"SUPPORT_API_TOKEN"},
+		},
+	},
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/guard"
+	"go.codycody31.dev/support/plugins"
+)
+
+// notServed lists the commands that cannot run over the API because they
+// are interactive, never return or replace the binary.
+var notServed = map[string]bool{
+	"self-update":     true,
+	"shell":           true,
+	"serve":           true,
+	"scheduler start": true,
+	"webhook serve":   true,
+}
+
+// Command describes a runnable command.
+type Command struct {
+	Path        string `json:"path"`
+	Usage       string `json:"usage,omitempty"`
+	Description string `json:"description,omitempty"`
+	ArgsUsage   string `json:"args_usage,omitempty"`
+	Plugin      string `json:"plugin,omitempty"`
+	// Destructive commands must be run with "yes" or "dry_run".
+	Destructive bool    `json:"destructive,omitempty"`
+	Flags       *Schema `json:"flags"`
+
+	command *cli.Command
+}
+
+// Schema is the JSON schema of the flags of a command.
+type Schema struct {
+	Type       string             `json:"type"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+
+	Description string      `json:"description,omitempty"`
+	Format      string      `json:"format,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+	Aliases     []string    `json:"x-aliases,omitempty"`
+}
+
+// commands returns the runnable commands of app, depth first.
+func commands(app *cli.App) []*Command {
+	var list []*Command
+
+	var walk func(path []string, cmds []*cli.Command)
+	walk = func(path []string, cmds []*cli.Command) {
+		for _, cmd := range cmds {
+			if cmd.Hidden || cmd.Name == "help" {
+				continue
+			}
+
+			cmdPath := append(append([]string(nil), path...), cmd.Name)
+			if len(cmd.Subcommands) > 0 {
+				walk(cmdPath, cmd.Subcommands)
+				continue
+			}
+
+			name := strings.Join(cmdPath, " ")
+			if cmd.Action == nil || notServed[name] {
+				continue
+			}
+
+			command := &Command{
+				Path:        name,
+				Usage:       cmd.Usage,
+				Description: cmd.Description,
+				ArgsUsage:   cmd.ArgsUsage,
+				Destructive: guard.IsDestructive(cmd),
+				Flags:       flagsSchema(cmd.Flags),
+				command:     cmd,
+			}
+			if p := plugins.ForCommand(cmdPath[0]); p != nil {
+				command.Plugin = p.Name
+			}
+			list = append(list, command)
+		}
+	}
+
+	walk(nil, app.Commands)
+	return list
+}
+
+func findCommand(app *cli.App, path string) *Command {
+	for _, cmd := range commands(app) {
+		if cmd.Path == path {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func flagsSchema(flags []cli.Flag) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for _, flag := range flags {
+		names := flag.Names()
+		if names[0] == "help" {
+			continue
+		}
+
+		property := flagSchema(flag)
+		if len(names) > 1 {
+			property.Aliases = names[1:]
+		}
+		if doc, ok := flag.(cli.DocGenerationFlag); ok {
+			property.Description = doc.GetUsage()
+		}
+		if required, ok := flag.(cli.RequiredFlag); ok && required.IsRequired() {
+			schema.Required = append(schema.Required, names[0])
+		}
+		schema.Properties[names[0]] = property
+	}
+	return schema
+}
+
+func flagSchema(flag cli.Flag) *Schema {
+	switch f := flag.(type) {
+	case *cli.BoolFlag:
+		return &Schema{Type: "boolean", Default: f.Value}
+	case *cli.IntFlag, *cli.Int64Flag, *cli.UintFlag, *cli.Uint64Flag:
+		return &Schema{Type: "integer"}
+	case *cli.Float64Flag:
+		return &Schema{Type: "number"}
+	case *cli.DurationFlag:
+		return &Schema{Type: "string", Format: "duration"}
+	case *cli.StringSliceFlag:
+		return &Schema{Type: "array", Items: &Schema{Type: "string"}}
+	case *cli.IntSliceFlag, *cli.Int64SliceFlag, *cli.UintSliceFlag, *cli.Uint64SliceFlag:
+		return &Schema{Type: "array", Items: &Schema{Type: "integer"}}
+	case *cli.Float64SliceFlag:
+		return &Schema{Type: "array", Items: &Schema{Type: "number"}}
+	case *cli.StringFlag:
+		schema := &Schema{Type: "string"}
+		if f.Value != "" {
+			schema.Default = f.Value
+		}
+		return schema
+	default:
+		return &Schema{Type: "string"}
+	}
+}
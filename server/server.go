@@ -0,0 +1,311 @@
+// Package server exposes the commands of support and its plugins as a local
+// HTTP/JSON API.
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/runner"
+)
+
+// Request is the body of a command invocation.
+type Request struct {
+	// Flags maps flag names to values: booleans, numbers, strings or arrays
+	// for flags given several times.
+	Flags   map[string]interface{} `json:"flags"`
+	Args    []string               `json:"args"`
+	Profile string                 `json:"profile"`
+	// Yes confirms destructive commands, DryRun only prints what they
+	// would do, like the global --yes and --dry-run flags.
+	Yes    bool `json:"yes"`
+	DryRun bool `json:"dry_run"`
+}
+
+// Result is the outcome of a command.
+type Result struct {
+	Command    string `json:"command"`
+	ExitCode   int    `json:"exit_code"`
+	ErrorKind  string `json:"error_kind,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+	Stdout     string `json:"stdout,omitempty"`
+	Stderr     string `json:"stderr,omitempty"`
+}
+
+// Event is a line of a streamed response: output chunks followed by the
+// result.
+type Event struct {
+	Stream string  `json:"stream,omitempty"`
+	Data   string  `json:"data,omitempty"`
+	Result *Result `json:"result,omitempty"`
+}
+
+type server struct {
+	app   *cli.App
+	token string
+}
+
+func Serve(c *cli.Context) error {
+	token := c.String("token")
+	if token == "" {
+		token = config.GetConfig().Serve.Token
+	}
+
+	var listener net.Listener
+	var err error
+	if socket := c.String("socket"); socket != "" {
+		os.Remove(socket)
+		listener, err = net.Listen("unix", socket)
+		if err == nil {
+			os.Chmod(socket, 0600)
+			defer os.Remove(socket)
+		}
+	} else {
+		if token == "" {
+			return errs.Config("a token is required to listen on TCP, use --token, SUPPORT_API_TOKEN or serve.token")
+		}
+		listener, err = net.Listen("tcp", c.String("listen"))
+	}
+	if err != nil {
+		return errs.Config("failed to listen: %v", err)
+	}
+
+	s := &server{app: c.App, token: token}
+	httpServer := &http.Server{Handler: s.handler()}
+
+	ctx := c.Context
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("serving the support API on %s", listener.Addr())
+	if err := httpServer.Serve(listener); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/commands", s.listCommands)
+	mux.HandleFunc("/v1/commands/", s.command)
+	return s.authenticate(mux)
+}
+
+func (s *server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" {
+			given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(given), []byte(s.token)) != 1 {
+				writeError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) listCommands(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, commands(s.app))
+}
+
+// command describes (GET) or runs (POST) the command whose path follows
+// /v1/commands/, with the words separated by slashes.
+func (s *server) command(w http.ResponseWriter, r *http.Request) {
+	path := strings.ReplaceAll(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/commands/"), "/"), "/", " ")
+	cmd := findCommand(s.app, path)
+	if cmd == nil {
+		writeError(w, http.StatusNotFound, "command "+path+" not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, cmd)
+	case http.MethodPost:
+		s.run(w, r, cmd)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *server) run(w http.ResponseWriter, r *http.Request, cmd *Command) {
+	req := &Request{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+	}
+
+	args, err := commandLine(cmd, req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stream := r.URL.Query().Get("stream") == "true" || strings.Contains(r.Header.Get("Accept"), "application/x-ndjson")
+	if stream {
+		s.runStreaming(w, r, cmd, args)
+		return
+	}
+
+	var stdout, stderr bytes.Buffer
+	result, err := runner.Run(r.Context(), args, nil, &stdout, &stderr)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	res := newResult(cmd, result)
+	res.Stdout = stdout.String()
+	res.Stderr = stderr.String()
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *server) runStreaming(w http.ResponseWriter, r *http.Request, cmd *Command, args []string) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	events := &eventWriter{w: w, encoder: json.NewEncoder(w)}
+	result, err := runner.Run(r.Context(), args, nil, events.stream("stdout"), events.stream("stderr"))
+	if err != nil {
+		events.write(Event{Stream: "stderr", Data: err.Error()})
+		events.write(Event{Result: &Result{Command: cmd.Path, ExitCode: errs.ExitCode(err)}})
+		return
+	}
+	events.write(Event{Result: newResult(cmd, result)})
+}
+
+func newResult(cmd *Command, result *runner.Result) *Result {
+	res := &Result{
+		Command:    cmd.Path,
+		ExitCode:   result.ExitCode,
+		DurationMS: result.Duration.Milliseconds(),
+	}
+	if result.ExitCode != 0 {
+		res.ErrorKind = errs.KindFromExitCode(result.ExitCode).String()
+	}
+	return res
+}
+
+// commandLine returns the arguments running cmd with the flags and
+// arguments of req.
+func commandLine(cmd *Command, req *Request) ([]string, error) {
+	var args []string
+	if req.Profile != "" {
+		args = append(args, "--profile", req.Profile)
+	}
+	if req.Yes {
+		args = append(args, "--yes")
+	}
+	if req.DryRun {
+		args = append(args, "--dry-run")
+	}
+	args = append(args, strings.Fields(cmd.Path)...)
+
+	names := make([]string, 0, len(req.Flags))
+	for name := range req.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !hasFlag(cmd.command, name) {
+			return nil, fmt.Errorf("unknown flag %q for %s", name, cmd.Path)
+		}
+
+		switch value := req.Flags[name].(type) {
+		case bool:
+			args = append(args, fmt.Sprintf("--%s=%t", name, value))
+		case []interface{}:
+			for _, item := range value {
+				args = append(args, fmt.Sprintf("--%s=%v", name, item))
+			}
+		case nil:
+		default:
+			args = append(args, fmt.Sprintf("--%s=%v", name, value))
+		}
+	}
+
+	for _, arg := range req.Args {
+		if strings.HasPrefix(arg, "-") {
+			args = append(args, "--")
+			break
+		}
+	}
+	return append(args, req.Args...), nil
+}
+
+func hasFlag(cmd *cli.Command, name string) bool {
+	for _, flag := range cmd.Flags {
+		for _, flagName := range flag.Names() {
+			if flagName == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// eventWriter writes output chunks as NDJSON events, flushing each.
+type eventWriter struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	encoder *json.Encoder
+}
+
+func (e *eventWriter) write(event Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.encoder.Encode(event)
+	if flusher, ok := e.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (e *eventWriter) stream(name string) *streamWriter {
+	return &streamWriter{events: e, name: name}
+}
+
+type streamWriter struct {
+	events *eventWriter
+	name   string
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	s.events.write(Event{Stream: s.name, Data: string(p)})
+	return len(p), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"go.codycody31.dev/support/shell"
+
+	"github.com/urfave/cli/v2"
+)
+
+var ShellCommand = &cli.Command{
+	Name:  "shell",
+	Usage: "Start an interactive shell running support commands",
+	Description: `Runs commands of support and its plugins without restarting. Besides
+   the commands of support, the shell understands:
+
+     profile [name]   show or change the profile used by the next commands
+     use <command>    run the next commands as subcommands of <command>
+     use ..           leave the innermost command of "use"
+     history          show the command history
+     exit, quit       leave the shell
+
+   History is kept in ~/.support/shell_history.`,
+	Action: shell.Run,
+}
@@ -0,0 +1,346 @@
+package shell
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// errInterrupted is returned by readLine when the line is abandoned with
+// Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// completeFunc returns the candidates for the last word of line.
+type completeFunc func(line string) []string
+
+// editor is a minimal single line editor for ANSI terminals supporting
+// cursor movement, history and tab completion.
+type editor struct {
+	in       *os.File
+	reader   *bufio.Reader
+	out      io.Writer
+	history  []string
+	complete completeFunc
+
+	// raw is the state of the terminal before readLine made it raw, nil
+	// when it is not.
+	rawMu sync.Mutex
+	raw   *term.State
+}
+
+func newEditor(in *os.File, out io.Writer, history []string, complete completeFunc) *editor {
+	return &editor{
+		in:       in,
+		reader:   bufio.NewReader(in),
+		out:      out,
+		history:  history,
+		complete: complete,
+	}
+}
+
+// interactive reports whether the editor reads from a terminal.
+func (e *editor) interactive() bool {
+	return term.IsTerminal(int(e.in.Fd()))
+}
+
+// addHistory appends line to the history unless it repeats the last entry.
+func (e *editor) addHistory(line string) {
+	if line == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+	e.history = append(e.history, line)
+}
+
+// readLine reads a line. It returns io.EOF on Ctrl-D on an empty line or at
+// the end of the input.
+func (e *editor) readLine(prompt string) (string, error) {
+	if !e.interactive() {
+		line, err := e.reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	state, err := term.MakeRaw(int(e.in.Fd()))
+	if err != nil {
+		return "", err
+	}
+	e.rawMu.Lock()
+	e.raw = state
+	e.rawMu.Unlock()
+	defer e.restore()
+
+	l := &lineState{editor: e, prompt: []rune(prompt), historyPos: len(e.history)}
+	l.refresh()
+
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(l.line), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(l.line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			l.deleteForward()
+		case 1: // Ctrl-A
+			l.pos = 0
+		case 5: // Ctrl-E
+			l.pos = len(l.line)
+		case 2: // Ctrl-B
+			l.moveLeft()
+		case 6: // Ctrl-F
+			l.moveRight()
+		case 8, 127: // Ctrl-H, Backspace
+			l.deleteBackward()
+		case 11: // Ctrl-K
+			l.line = l.line[:l.pos]
+		case 21: // Ctrl-U
+			l.line = l.line[l.pos:]
+			l.pos = 0
+		case 23: // Ctrl-W
+			l.deleteWord()
+		case 12: // Ctrl-L
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case 16: // Ctrl-P
+			l.historyPrev()
+		case 14: // Ctrl-N
+			l.historyNext()
+		case '\t':
+			l.completeWord()
+		case 27: // Escape sequence
+			l.escape()
+		default:
+			if r >= ' ' {
+				l.insert(r)
+			}
+		}
+		l.refresh()
+	}
+}
+
+// restore restores the terminal made raw by readLine.
+func (e *editor) restore() {
+	e.rawMu.Lock()
+	defer e.rawMu.Unlock()
+	if e.raw != nil {
+		term.Restore(int(e.in.Fd()), e.raw)
+		e.raw = nil
+	}
+}
+
+// readLineContext is readLine returning the error of ctx, with the
+// terminal restored, when ctx is done first.
+func (e *editor) readLineContext(ctx context.Context, prompt string) (string, error) {
+	type result struct {
+		line string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		line, err := e.readLine(prompt)
+		done <- result{line, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.line, r.err
+	case <-ctx.Done():
+		e.restore()
+		fmt.Fprint(e.out, "\r\n")
+		return "", ctx.Err()
+	}
+}
+
+// lineState is the state of the line being edited.
+type lineState struct {
+	*editor
+	prompt     []rune
+	line       []rune
+	pos        int
+	historyPos int
+	// pending keeps the edited line while browsing the history.
+	pending []rune
+}
+
+func (l *lineState) refresh() {
+	fmt.Fprintf(l.out, "\r%s%s\x1b[K\r", string(l.prompt), string(l.line))
+	if offset := len(l.prompt) + l.pos; offset > 0 {
+		fmt.Fprintf(l.out, "\x1b[%dC", offset)
+	}
+}
+
+func (l *lineState) insert(r rune) {
+	l.line = append(l.line[:l.pos], append([]rune{r}, l.line[l.pos:]...)...)
+	l.pos++
+}
+
+func (l *lineState) moveLeft() {
+	if l.pos > 0 {
+		l.pos--
+	}
+}
+
+func (l *lineState) moveRight() {
+	if l.pos < len(l.line) {
+		l.pos++
+	}
+}
+
+func (l *lineState) deleteBackward() {
+	if l.pos > 0 {
+		l.line = append(l.line[:l.pos-1], l.line[l.pos:]...)
+		l.pos--
+	}
+}
+
+func (l *lineState) deleteForward() {
+	if l.pos < len(l.line) {
+		l.line = append(l.line[:l.pos], l.line[l.pos+1:]...)
+	}
+}
+
+func (l *lineState) deleteWord() {
+	start := l.pos
+	for start > 0 && l.line[start-1] == ' ' {
+		start--
+	}
+	for start > 0 && l.line[start-1] != ' ' {
+		start--
+	}
+	l.line = append(l.line[:start], l.line[l.pos:]...)
+	l.pos = start
+}
+
+func (l *lineState) historyPrev() {
+	if l.historyPos == 0 {
+		return
+	}
+	if l.historyPos == len(l.history) {
+		l.pending = l.line
+	}
+	l.historyPos--
+	l.line = []rune(l.history[l.historyPos])
+	l.pos = len(l.line)
+}
+
+func (l *lineState) historyNext() {
+	if l.historyPos >= len(l.history) {
+		return
+	}
+	l.historyPos++
+	if l.historyPos == len(l.history) {
+		l.line = l.pending
+	} else {
+		l.line = []rune(l.history[l.historyPos])
+	}
+	l.pos = len(l.line)
+}
+
+// escape handles the ANSI sequences sent by the arrow, home, end and delete
+// keys.
+func (l *lineState) escape() {
+	r, _, err := l.reader.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return
+	}
+
+	r, _, err = l.reader.ReadRune()
+	if err != nil {
+		return
+	}
+
+	switch r {
+	case 'A':
+		l.historyPrev()
+	case 'B':
+		l.historyNext()
+	case 'C':
+		l.moveRight()
+	case 'D':
+		l.moveLeft()
+	case 'H':
+		l.pos = 0
+	case 'F':
+		l.pos = len(l.line)
+	case '1', '3', '4', '7', '8':
+		// Sequences of the form ESC [ n ~
+		if next, _, err := l.reader.ReadRune(); err != nil || next != '~' {
+			return
+		}
+		switch r {
+		case '1', '7':
+			l.pos = 0
+		case '4', '8':
+			l.pos = len(l.line)
+		case '3':
+			l.deleteForward()
+		}
+	}
+}
+
+// completeWord completes the word before the cursor. A single candidate is
+// inserted, otherwise the longest common prefix is inserted and the
+// candidates are listed.
+func (l *lineState) completeWord() {
+	if l.complete == nil {
+		return
+	}
+
+	before := string(l.line[:l.pos])
+	candidates := l.complete(before)
+	if len(candidates) == 0 {
+		return
+	}
+
+	start := strings.LastIndexAny(before, " \t") + 1
+	word := before[start:]
+
+	completion := candidates[0]
+	if len(candidates) == 1 {
+		completion += " "
+	} else {
+		for _, candidate := range candidates[1:] {
+			completion = commonPrefix(completion, candidate)
+		}
+	}
+
+	if len(completion) > len(word) {
+		after := l.line[l.pos:]
+		l.line = append([]rune(before[:start]+completion), after...)
+		l.pos = len([]rune(before[:start] + completion))
+		return
+	}
+
+	if len(candidates) > 1 {
+		sorted := append([]string(nil), candidates...)
+		sort.Strings(sorted)
+		fmt.Fprintf(l.out, "\r\n%s\r\n", strings.Join(sorted, "  "))
+	}
+}
+
+func commonPrefix(a, b string) string {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return a[:i]
+}
@@ -0,0 +1,90 @@
+package shell
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.codycody31.dev/support/audit"
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/shlex"
+)
+
+// maxHistory is the number of lines kept in the history file.
+const maxHistory = 1000
+
+func historyFilePath() string {
+	return filepath.Join(config.SupportDir(), "shell_history")
+}
+
+// loadHistory returns the lines of the history file, oldest first.
+func loadHistory() []string {
+	file, err := os.Open(historyFilePath())
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	if len(lines) > maxHistory {
+		lines = lines[len(lines)-maxHistory:]
+	}
+	return lines
+}
+
+// saveHistory rewrites the history file with the last maxHistory lines,
+// with secrets redacted as in the audit log.
+func saveHistory(lines []string) error {
+	if len(lines) > maxHistory {
+		lines = lines[len(lines)-maxHistory:]
+	}
+
+	path := historyFilePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for _, line := range lines {
+		writer.WriteString(redactLine(line))
+		writer.WriteByte('\n')
+	}
+	return writer.Flush()
+}
+
+// redactLine returns line with the values of secret flags and anything else
+// looking like a secret redacted. Lines with nothing to redact are returned
+// as typed.
+func redactLine(line string) string {
+	words, err := shlex.Split(line)
+	if err != nil {
+		return audit.RedactArgs([]string{line})[0]
+	}
+
+	redactedWords := audit.RedactArgs(words)
+	changed := false
+	for i := range words {
+		if redactedWords[i] != words[i] {
+			changed = true
+		}
+		redactedWords[i] = shlex.Quote(redactedWords[i])
+	}
+	if !changed {
+		return line
+	}
+	return strings.Join(redactedWords, " ")
+}
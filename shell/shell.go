@@ -0,0 +1,199 @@
+// Package shell implements `support shell`, an interactive prompt running
+// commands of the loaded app without starting a new process for each.
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/alias"
+	"go.codycody31.dev/support/completion"
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/host"
+	"go.codycody31.dev/support/shlex"
+)
+
+// session is the state kept between the lines of a shell.
+type session struct {
+	app     *cli.App
+	profile string
+	// context is prepended to every line, so that after `use caprover`
+	// typing `list` runs `caprover list`.
+	context []string
+	editor  *editor
+}
+
+func Run(c *cli.Context) error {
+	s := &session{
+		app:     c.App,
+		profile: config.Profile(),
+	}
+	s.editor = newEditor(os.Stdin, os.Stdout, loadHistory(), s.complete)
+
+	// Commands run in the shell select their own profile
+	defer config.SetProfile(config.Profile())
+
+	if s.editor.interactive() {
+		fmt.Println("Type \"help\" for the list of commands, \"exit\" to quit.")
+	}
+
+	var stopped error
+	for {
+		// SIGINT, when not read as Ctrl-C, and SIGTERM end the shell
+		line, err := s.editor.readLineContext(c.Context, s.prompt())
+		if errors.Is(err, errInterrupted) {
+			continue
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil && c.Context.Err() != nil {
+			stopped = fmt.Errorf("shell stopped: %w", err)
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		s.editor.addHistory(line)
+
+		words, err := shlex.Split(line)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			continue
+		}
+
+		if words[0] == "exit" || words[0] == "quit" {
+			break
+		}
+
+		if err := s.execute(words); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
+
+	if err := saveHistory(s.editor.history); err != nil {
+		fmt.Fprintln(os.Stderr, "Error saving shell history:", err)
+	}
+	return stopped
+}
+
+func (s *session) prompt() string {
+	prompt := s.app.Name
+	if s.profile != config.DefaultProfile {
+		prompt += "[" + s.profile + "]"
+	}
+	if len(s.context) > 0 {
+		prompt += " " + strings.Join(s.context, " ")
+	}
+	return prompt + "> "
+}
+
+// execute runs a built in shell command or a command of the app.
+func (s *session) execute(words []string) error {
+	switch words[0] {
+	case "profile":
+		if len(words) == 1 {
+			fmt.Println(s.profile)
+			return nil
+		}
+		s.profile = words[1]
+		return nil
+	case "use":
+		return s.use(words[1:])
+	case "history":
+		for i, line := range s.editor.history {
+			fmt.Printf("%5d  %s\n", i+1, line)
+		}
+		return nil
+	case "shell":
+		return errs.Usage("already in a shell")
+	}
+
+	args := append([]string{s.app.Name, "--profile", s.profile}, s.context...)
+	args, err := alias.Expand(s.app, append(args, words...))
+	if err != nil {
+		return err
+	}
+
+	// Ctrl-C cancels the command instead of the shell
+	ctx, release := host.Interruptible(context.Background())
+	defer release()
+	return s.app.RunContext(ctx, args)
+}
+
+// use changes the command context. `use ..` leaves the innermost command and
+// `use` alone leaves all of them.
+func (s *session) use(path []string) error {
+	if len(path) == 0 {
+		s.context = nil
+		return nil
+	}
+	if len(path) == 1 && path[0] == ".." {
+		if len(s.context) > 0 {
+			s.context = s.context[:len(s.context)-1]
+		}
+		return nil
+	}
+
+	context := append(append([]string(nil), s.context...), path...)
+	commands := s.app.Commands
+	for _, name := range context {
+		cmd := findCommand(commands, name)
+		if cmd == nil {
+			return errs.Usage("unknown command %q", strings.Join(context, " "))
+		}
+		if len(cmd.Subcommands) == 0 {
+			return errs.Usage("%q has no subcommands", strings.Join(context, " "))
+		}
+		commands = cmd.Subcommands
+	}
+
+	s.context = context
+	return nil
+}
+
+var builtins = []string{"exit", "quit", "profile", "use", "history"}
+
+// complete returns the candidates for the last word of line, taking the
+// command context into account.
+func (s *session) complete(line string) []string {
+	words, err := shlex.Split(line)
+	if err != nil {
+		return nil
+	}
+
+	// The word being completed is empty when the line ends with a space
+	if len(words) == 0 || strings.HasSuffix(line, " ") {
+		words = append(words, "")
+	}
+
+	candidates := completion.Complete(s.app, append(append([]string(nil), s.context...), words...))
+	if len(s.context) == 0 && len(words) == 1 {
+		for _, name := range builtins {
+			if strings.HasPrefix(name, words[0]) {
+				candidates = append(candidates, name)
+			}
+		}
+	}
+	return candidates
+}
+
+func findCommand(commands []*cli.Command, name string) *cli.Command {
+	for _, cmd := range commands {
+		if cmd.HasName(name) {
+			return cmd
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"time"
+
+	"go.codycody31.dev/support/stats"
+
+	"github.com/urfave/cli/v2"
+)
+
+var StatsCommand = &cli.Command{
+	Name:  "stats",
+	Usage: "Show the usage and timing of commands, routines and plugins",
+	Description: `Statistics are recorded in ~/.support/stats.log and never leave this
+   machine. Disable them with stats.disabled in config.yaml.`,
+	Action: stats.ShowStats,
+	Flags: []cli.Flag{
+		&cli.DurationFlag{
+			Name:    "since",
+			Aliases: []string{"s"},
+			Usage:   "Time window of the report",
+			Value:   7 * 24 * time.Hour,
+		},
+		&cli.StringFlag{
+			Name:  "sort",
+			Usage: "Sort the commands by runs, avg, p95 or failures",
+			Value: "runs",
+		},
+		&cli.IntFlag{
+			Name:    "limit",
+			Aliases: []string{"n"},
+			Usage:   "Maximum number of rows of each table",
+			Value:   20,
+		},
+		&cli.StringFlag{
+			Name:    "format",
+			Aliases: []string{"f"},
+			Usage:   "Output format: text or prometheus",
+			Value:   "text",
+		},
+	},
+}
@@ -0,0 +1,33 @@
+package stats
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/errs"
+)
+
+func ShowStats(c *cli.Context) error {
+	since := time.Now().Add(-c.Duration("since"))
+	records, err := readRecords(since)
+	if err != nil {
+		return fmt.Errorf("failed to read stats: %v", err)
+	}
+
+	report := NewReport(since, records)
+	if err := report.SortCommands(c.String("sort")); err != nil {
+		return errs.Usage("%v", err)
+	}
+
+	switch c.String("format") {
+	case "text":
+		report.WriteText(os.Stdout, c.Int("limit"))
+	case "prometheus":
+		report.WritePrometheus(os.Stdout)
+	default:
+		return errs.Usage("unknown format %q, expected text or prometheus", c.String("format"))
+	}
+	return nil
+}
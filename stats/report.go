@@ -0,0 +1,256 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Summary aggregates the records of a command, a routine or a plugin.
+type Summary struct {
+	Kind      string
+	Name      string
+	Plugin    string
+	Runs      int
+	Failures  int
+	durations []time.Duration
+	total     time.Duration
+}
+
+func (s *Summary) add(record *Record) {
+	s.Runs++
+	if record.ExitCode != 0 {
+		s.Failures++
+	}
+	d := record.Duration()
+	s.total += d
+	s.durations = append(s.durations, d)
+}
+
+// FailureRate is the share of failed runs, between 0 and 1.
+func (s *Summary) FailureRate() float64 {
+	if s.Runs == 0 {
+		return 0
+	}
+	return float64(s.Failures) / float64(s.Runs)
+}
+
+func (s *Summary) Total() time.Duration {
+	return s.total
+}
+
+func (s *Summary) Average() time.Duration {
+	if s.Runs == 0 {
+		return 0
+	}
+	return s.total / time.Duration(s.Runs)
+}
+
+// Percentile returns the duration p percent of the runs did not exceed.
+func (s *Summary) Percentile(p float64) time.Duration {
+	if len(s.durations) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), s.durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	index := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	return sorted[index]
+}
+
+func (s *Summary) Max() time.Duration {
+	return s.Percentile(100)
+}
+
+// Report is the usage over a time window.
+type Report struct {
+	Since    time.Time
+	Commands []*Summary
+	Routines []*Summary
+	Plugins  []*Summary
+}
+
+// NewReport aggregates the records by kind and name.
+func NewReport(since time.Time, records []*Record) *Report {
+	summaries := make(map[string]*Summary)
+	report := &Report{Since: since}
+
+	for _, record := range records {
+		key := record.Kind + "\x00" + record.Name
+		summary, ok := summaries[key]
+		if !ok {
+			summary = &Summary{Kind: record.Kind, Name: record.Name, Plugin: record.Plugin}
+			summaries[key] = summary
+
+			switch record.Kind {
+			case KindCommand:
+				report.Commands = append(report.Commands, summary)
+			case KindRoutine:
+				report.Routines = append(report.Routines, summary)
+			case KindPluginLoad:
+				report.Plugins = append(report.Plugins, summary)
+			}
+		}
+		summary.add(record)
+	}
+
+	// Slowest routines and plugins first
+	bySlowest := func(summaries []*Summary) {
+		sort.Slice(summaries, func(i, j int) bool {
+			return summaries[i].Average() > summaries[j].Average()
+		})
+	}
+	bySlowest(report.Routines)
+	bySlowest(report.Plugins)
+	return report
+}
+
+// SortCommands sorts the commands by runs, average or p95 duration, or
+// failure rate, the highest first.
+func (r *Report) SortCommands(by string) error {
+	var less func(a, b *Summary) bool
+	switch by {
+	case "runs":
+		less = func(a, b *Summary) bool { return a.Runs > b.Runs }
+	case "avg":
+		less = func(a, b *Summary) bool { return a.Average() > b.Average() }
+	case "p95":
+		less = func(a, b *Summary) bool { return a.Percentile(95) > b.Percentile(95) }
+	case "failures":
+		less = func(a, b *Summary) bool { return a.FailureRate() > b.FailureRate() }
+	default:
+		return fmt.Errorf("unknown sort %q, expected runs, avg, p95 or failures", by)
+	}
+
+	sort.SliceStable(r.Commands, func(i, j int) bool { return less(r.Commands[i], r.Commands[j]) })
+	return nil
+}
+
+// WriteText writes the report as tables, limited to limit rows each when
+// limit is positive.
+func (r *Report) WriteText(w io.Writer, limit int) {
+	runs := 0
+	for _, command := range r.Commands {
+		runs += command.Runs
+	}
+	fmt.Fprintf(w, "Usage since %s: %d command runs\n", r.Since.Local().Format(time.RFC3339), runs)
+	if runs == 0 && len(r.Routines) == 0 {
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(tw, "COMMAND\tPLUGIN\tRUNS\tFAILED\tFAIL%\tAVG\tP95\tMAX")
+	for _, s := range first(r.Commands, limit) {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%.1f%%\t%s\t%s\t%s\n", s.Name, dash(s.Plugin), s.Runs, s.Failures, s.FailureRate()*100,
+			round(s.Average()), round(s.Percentile(95)), round(s.Max()))
+	}
+	tw.Flush()
+
+	if len(r.Routines) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(tw, "ROUTINE\tRUNS\tFAILED\tFAIL%\tAVG\tP95\tMAX")
+		for _, s := range first(r.Routines, limit) {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f%%\t%s\t%s\t%s\n", s.Name, s.Runs, s.Failures, s.FailureRate()*100,
+				round(s.Average()), round(s.Percentile(95)), round(s.Max()))
+		}
+		tw.Flush()
+	}
+
+	if len(r.Plugins) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(tw, "PLUGIN\tLOADS\tAVG LOAD\tMAX LOAD")
+		for _, s := range first(r.Plugins, limit) {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", s.Name, s.Runs, round(s.Average()), round(s.Max()))
+		}
+		tw.Flush()
+	}
+}
+
+// WritePrometheus writes the report in the Prometheus text exposition
+// format. Counters cover the time window of the report.
+func (r *Report) WritePrometheus(w io.Writer) {
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	metric("support_command_runs_total", "counter", "Number of runs of a command.")
+	for _, s := range r.Commands {
+		fmt.Fprintf(w, "support_command_runs_total{%s} %d\n", commandLabels(s), s.Runs)
+	}
+	metric("support_command_failures_total", "counter", "Number of failed runs of a command.")
+	for _, s := range r.Commands {
+		fmt.Fprintf(w, "support_command_failures_total{%s} %d\n", commandLabels(s), s.Failures)
+	}
+	metric("support_command_duration_seconds", "summary", "Duration of the runs of a command.")
+	for _, s := range r.Commands {
+		writeSummary(w, "support_command_duration_seconds", commandLabels(s), s)
+	}
+
+	metric("support_routine_runs_total", "counter", "Number of runs of a routine.")
+	for _, s := range r.Routines {
+		fmt.Fprintf(w, "support_routine_runs_total{%s} %d\n", label("routine", s.Name), s.Runs)
+	}
+	metric("support_routine_failures_total", "counter", "Number of failed runs of a routine.")
+	for _, s := range r.Routines {
+		fmt.Fprintf(w, "support_routine_failures_total{%s} %d\n", label("routine", s.Name), s.Failures)
+	}
+	metric("support_routine_duration_seconds", "summary", "Duration of the runs of a routine.")
+	for _, s := range r.Routines {
+		writeSummary(w, "support_routine_duration_seconds", label("routine", s.Name), s)
+	}
+
+	metric("support_plugin_load_duration_seconds", "summary", "Time taken to load a plugin.")
+	for _, s := range r.Plugins {
+		writeSummary(w, "support_plugin_load_duration_seconds", label("plugin", s.Name), s)
+	}
+}
+
+func writeSummary(w io.Writer, name, labels string, s *Summary) {
+	for _, quantile := range []float64{0.5, 0.95} {
+		fmt.Fprintf(w, "%s{%s,quantile=\"%g\"} %g\n", name, labels, quantile, s.Percentile(quantile*100).Seconds())
+	}
+	fmt.Fprintf(w, "%s_sum{%s} %g\n", name, labels, s.Total().Seconds())
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, s.Runs)
+}
+
+func commandLabels(s *Summary) string {
+	return label("command", s.Name) + "," + label("plugin", s.Plugin)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func label(name, value string) string {
+	return name + `="` + labelEscaper.Replace(value) + `"`
+}
+
+func first(summaries []*Summary, n int) []*Summary {
+	if n > 0 && len(summaries) > n {
+		return summaries[:n]
+	}
+	return summaries
+}
+
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(100 * time.Microsecond)
+	}
+	return d.Round(time.Microsecond)
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,230 @@
+// Package stats records how commands, routines and plugins are used, in a
+// local file only, and reports on it with `support stats`.
+package stats
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/host"
+	"go.codycody31.dev/support/plugins"
+)
+
+// Kinds of records.
+const (
+	KindCommand    = "command"
+	KindRoutine    = "routine"
+	KindPluginLoad = "plugin_load"
+)
+
+const (
+	defaultRetention = 90 * 24 * time.Hour
+	// pruneSize is the size of the file above which old records are
+	// removed.
+	pruneSize = 4 << 20
+)
+
+// Record is a run of a command or a routine, or the loading of a plugin.
+type Record struct {
+	Time time.Time `json:"time"`
+	Kind string    `json:"kind"`
+	// Name is the path of the command, e.g. "caprover list", the name of
+	// the routine or of the plugin.
+	Name       string  `json:"name"`
+	Plugin     string  `json:"plugin,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+	ExitCode   int     `json:"exit_code"`
+}
+
+func (r *Record) Duration() time.Duration {
+	return time.Duration(r.DurationMS * float64(time.Millisecond))
+}
+
+func filePath() string {
+	return filepath.Join(config.SupportDir(), "stats.log")
+}
+
+// Wrap wraps the action of every runnable command so that its duration and
+// exit code are recorded, along with the load time of the plugins. Hidden
+// commands, such as __complete run by the completion scripts on every key
+// press, are not recorded.
+func Wrap(commands []*cli.Command) {
+	for _, cmd := range commands {
+		if cmd.Hidden {
+			continue
+		}
+		if cmd.Action != nil && len(cmd.Subcommands) == 0 {
+			cmd.Action = wrapAction(cmd.Action)
+		}
+		Wrap(cmd.Subcommands)
+	}
+}
+
+func wrapAction(action cli.ActionFunc) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		start := time.Now()
+		err := action(c)
+
+		path := host.CommandPath(c)
+		records := []*Record{{
+			Time:       start.UTC(),
+			Kind:       KindCommand,
+			Name:       strings.Join(path, " "),
+			DurationMS: milliseconds(time.Since(start)),
+			ExitCode:   errs.ExitCode(err),
+		}}
+		if len(path) > 0 {
+			if p := plugins.ForCommand(path[0]); p != nil {
+				records[0].Plugin = p.Name
+			}
+		}
+		if recordsPluginLoads(path) {
+			for _, p := range plugins.Loaded() {
+				records = append(records, &Record{
+					Time:       start.UTC(),
+					Kind:       KindPluginLoad,
+					Name:       p.Name,
+					DurationMS: milliseconds(p.LoadTime),
+				})
+			}
+		}
+
+		Write(records...)
+		return err
+	}
+}
+
+// recordsPluginLoads reports whether the load time of the plugins is
+// recorded along with the command path. The completion script is generated
+// whenever a shell starts, which would drown the load times of the commands
+// actually used.
+func recordsPluginLoads(path []string) bool {
+	return len(path) > 0 && path[0] != "completion"
+}
+
+// RecordRoutine records a run of the routine name.
+func RecordRoutine(name string, start time.Time, err error) {
+	Write(&Record{
+		Time:       start.UTC(),
+		Kind:       KindRoutine,
+		Name:       name,
+		DurationMS: milliseconds(time.Since(start)),
+		ExitCode:   errs.ExitCode(err),
+	})
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// Write appends records to the stats file, unless stats are disabled.
+// Failures are reported on stderr, they never fail the command.
+func Write(records ...*Record) {
+	if config.GetConfig().Stats.Disabled {
+		return
+	}
+
+	// A single write, so that concurrent runs do not interleave lines
+	var buf bytes.Buffer
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error encoding stats record:", err)
+			return
+		}
+		buf.Write(append(line, '\n'))
+	}
+
+	if err := appendLines(buf.Bytes()); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing stats:", err)
+	}
+}
+
+func appendLines(lines []byte) error {
+	path := filePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(lines); err != nil {
+		return err
+	}
+
+	if info, err := file.Stat(); err == nil && info.Size() > pruneSize {
+		return prune()
+	}
+	return nil
+}
+
+// prune rewrites the stats file without the records older than the
+// retention.
+func prune() error {
+	retention := config.GetConfig().Stats.Retention
+	if retention <= 0 {
+		retention = defaultRetention
+	}
+
+	records, err := readRecords(time.Now().Add(-retention))
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+	}
+
+	// Replace the file with a rename, so that readers never see it partly
+	// written
+	path := filePath()
+	if err := os.WriteFile(path+".tmp", buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// readRecords returns the records more recent than since.
+func readRecords(since time.Time) ([]*Record, error) {
+	file, err := os.Open(filePath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []*Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		record := &Record{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			// Skip lines cut short by a crash
+			continue
+		}
+		if record.Time.Before(since) {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
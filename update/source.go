@@ -0,0 +1,181 @@
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/host"
+)
+
+// DefaultURL is the GitHub repository releases are downloaded from.
+const DefaultURL = "https://github.com/Codycody31/support"
+
+// Channels releases are published on.
+const (
+	ChannelStable = "stable"
+	ChannelBeta   = "beta"
+)
+
+// source is where releases are downloaded from.
+//
+// Mirrors and local directories use the layout:
+//
+//	<url>/<channel>/VERSION       latest version of the channel
+//	<url>/<version>/<asset>       assets of a release
+type source interface {
+	// latest returns the latest version released on channel.
+	latest(ctx context.Context, channel string) (string, error)
+	// open returns the asset name of the release version.
+	open(ctx context.Context, version, name string) (io.ReadCloser, error)
+}
+
+func newSource(url string) source {
+	url = strings.TrimSuffix(url, "/")
+	switch {
+	case url == "":
+		return &githubSource{repo: strings.TrimPrefix(DefaultURL, "https://github.com/")}
+	case strings.HasPrefix(url, "https://github.com/"):
+		return &githubSource{repo: strings.TrimPrefix(url, "https://github.com/")}
+	case strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://"):
+		return &mirrorSource{url: url}
+	default:
+		return &dirSource{dir: strings.TrimPrefix(url, "file://")}
+	}
+}
+
+// githubSource downloads the releases of a GitHub repository. Beta releases
+// are the prereleases.
+type githubSource struct {
+	repo string
+}
+
+type githubRelease struct {
+	TagName    string `json:"tag_name"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
+}
+
+func (s *githubSource) latest(ctx context.Context, channel string) (string, error) {
+	api := "https://api.github.com/repos/" + s.repo + "/releases"
+	if channel == ChannelStable {
+		var release githubRelease
+		if err := getJSON(ctx, api+"/latest", &release); err != nil {
+			return "", err
+		}
+		return release.TagName, nil
+	}
+
+	// The most recent release, prerelease or not
+	var releases []githubRelease
+	if err := getJSON(ctx, api+"?per_page=20", &releases); err != nil {
+		return "", err
+	}
+	for _, release := range releases {
+		if !release.Draft {
+			return release.TagName, nil
+		}
+	}
+	return "", errs.NotFound("no release found in %s", s.repo)
+}
+
+func (s *githubSource) open(ctx context.Context, version, name string) (io.ReadCloser, error) {
+	return get(ctx, fmt.Sprintf("https://github.com/%s/releases/download/%s/%s", s.repo, version, name))
+}
+
+// mirrorSource downloads releases from an HTTP server.
+type mirrorSource struct {
+	url string
+}
+
+func (s *mirrorSource) latest(ctx context.Context, channel string) (string, error) {
+	body, err := get(ctx, s.url+"/"+channel+"/VERSION")
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	return readVersion(body)
+}
+
+func (s *mirrorSource) open(ctx context.Context, version, name string) (io.ReadCloser, error) {
+	return get(ctx, s.url+"/"+version+"/"+name)
+}
+
+// dirSource reads releases from a local directory.
+type dirSource struct {
+	dir string
+}
+
+func (s *dirSource) latest(ctx context.Context, channel string) (string, error) {
+	file, err := s.open(ctx, channel, "VERSION")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return readVersion(file)
+}
+
+func (s *dirSource) open(ctx context.Context, version, name string) (io.ReadCloser, error) {
+	file, err := os.Open(filepath.Join(s.dir, version, name))
+	if os.IsNotExist(err) {
+		return nil, errs.NotFound("%s not found in release %s", name, version)
+	}
+	return file, err
+}
+
+func readVersion(r io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, 256))
+	if err != nil {
+		return "", err
+	}
+	version := strings.TrimSpace(string(data))
+	if version == "" || strings.ContainsAny(version, "/\\ \n") {
+		return "", errs.RemoteAPI("invalid version %q", version)
+	}
+	return version, nil
+}
+
+func get(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, errs.Config("invalid release URL %s: %v", url, err)
+	}
+
+	client, err := host.HTTPClient("update")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errs.Network("failed to download %s: %w", url, err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, errs.NotFound("%s not found", url)
+	case resp.StatusCode != http.StatusOK:
+		resp.Body.Close()
+		return nil, errs.RemoteStatus(resp.StatusCode, "failed to download %s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func getJSON(ctx context.Context, url string, v interface{}) error {
+	body, err := get(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		return errs.RemoteAPI("invalid response from %s: %v", url, err)
+	}
+	return nil
+}
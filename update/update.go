@@ -0,0 +1,262 @@
+// Package update implements `support self-update`, replacing the running
+// binary with a verified release.
+package update
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/host"
+)
+
+// maxChecksumsSize limits the size of the checksum file and its signature.
+const maxChecksumsSize = 1 << 20
+
+// assetName returns the name of the binary for this platform.
+func assetName() string {
+	return fmt.Sprintf("support_%s_%s", runtime.GOOS, runtime.GOARCH)
+}
+
+// executable returns the path of the running binary, symlinks resolved so
+// that the binary itself is replaced.
+func executable() (string, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the support binary: %v", err)
+	}
+	return filepath.EvalSymlinks(path)
+}
+
+func SelfUpdate(c *cli.Context) error {
+	if c.Bool("rollback") {
+		return Rollback(c)
+	}
+
+	settings := config.GetConfig().Update
+	channel := settings.Channel
+	if c.IsSet("channel") || channel == "" {
+		channel = c.String("channel")
+	}
+	if channel != ChannelStable && channel != ChannelBeta {
+		return errs.Usage("unknown channel %q, expected %s or %s", channel, ChannelStable, ChannelBeta)
+	}
+	url := settings.URL
+	if c.IsSet("url") {
+		url = c.String("url")
+	}
+	src := newSource(url)
+
+	current := c.App.Version
+	version := c.String("version")
+	if version == "" {
+		latest, err := src.latest(c.Context, channel)
+		if err != nil {
+			return fmt.Errorf("failed to find the latest %s release: %w", channel, err)
+		}
+		version = latest
+	}
+
+	fmt.Printf("Current version: %s\n", current)
+	fmt.Printf("Latest %s version: %s\n", channel, version)
+	if version == current && !c.Bool("force") {
+		fmt.Println("support is up to date")
+		return nil
+	}
+	if c.Bool("check") {
+		fmt.Println("An update is available, install it with `support self-update`")
+		return nil
+	}
+
+	exe, err := executable()
+	if err != nil {
+		return err
+	}
+
+	// The new binary is written next to the current one, so that it can
+	// be renamed over it
+	next := exe + ".new"
+	if err := download(c.Context, src, version, next); err != nil {
+		os.Remove(next)
+		return err
+	}
+
+	if err := checkPlugins(c.Context, next); err != nil {
+		if !c.Bool("force") {
+			os.Remove(next)
+			return errs.PluginLoad("the enabled plugins are not compatible with %s, rebuild them or run with --force: %v", version, err)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: the enabled plugins are not compatible with %s and must be rebuilt\n", version)
+	}
+
+	if err := replace(exe, next); err != nil {
+		os.Remove(next)
+		return err
+	}
+
+	fmt.Printf("Updated support from %s to %s\n", current, version)
+	fmt.Printf("The previous binary is kept as %s, restore it with `support self-update --rollback`\n", exe+".prev")
+	return nil
+}
+
+// download writes the binary of the release version to path, once its
+// checksum and the signature of the checksums have been verified.
+func download(ctx context.Context, src source, version, path string) error {
+	checksums, err := readAll(ctx, src, version, checksumsName)
+	if err != nil {
+		return err
+	}
+	signature, err := readAll(ctx, src, version, signatureName)
+	if err != nil {
+		return err
+	}
+	if err := verifySignature(checksums, signature); err != nil {
+		return err
+	}
+
+	name := assetName()
+	expected, err := checksum(checksums, name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Downloading %s %s...\n", name, version)
+	body, err := src.open(ctx, version, name)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to write the new binary: %v", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(file, hash), body); err != nil {
+		return errs.Network("failed to download %s: %w", name, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write the new binary: %v", err)
+	}
+
+	if !bytes.Equal(hash.Sum(nil), expected) {
+		return fmt.Errorf("checksum mismatch for %s, the download may be corrupted", name)
+	}
+	fmt.Println("Verified the checksum and signature")
+	return nil
+}
+
+func readAll(ctx context.Context, src source, version, name string) ([]byte, error) {
+	body, err := src.open(ctx, version, name)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, maxChecksumsSize))
+	if err != nil {
+		return nil, errs.Network("failed to download %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// checkPlugins runs `plugins check` with the binary at path, so that
+// plugins built against the running version are known to still load.
+func checkPlugins(ctx context.Context, path string) error {
+	fmt.Println("Checking the enabled plugins...")
+
+	// The report is written to stdout, the errors of the loader to stderr
+	// would repeat it
+	var output bytes.Buffer
+	cmd := exec.Command(path, "plugins", "check")
+	cmd.Stdout = &output
+	if err := host.Run(ctx, cmd); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return fmt.Errorf("%v\n%s", err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// replace moves next over exe, keeping exe as exe.prev. The binary is
+// replaced with a rename, so that exe is never missing or partly written.
+func replace(exe, next string) error {
+	prev := exe + ".prev"
+	if err := os.Remove(prev); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove the previous binary: %v", err)
+	}
+	if err := link(exe, prev); err != nil {
+		return fmt.Errorf("failed to keep the current binary: %v", err)
+	}
+	if err := os.Rename(next, exe); err != nil {
+		return fmt.Errorf("failed to replace the binary: %v", err)
+	}
+	return nil
+}
+
+// Rollback restores the binary replaced by the last update. The replaced
+// binary becomes exe.prev, so a second rollback undoes the first.
+func Rollback(c *cli.Context) error {
+	exe, err := executable()
+	if err != nil {
+		return err
+	}
+
+	prev := exe + ".prev"
+	if _, err := os.Stat(prev); os.IsNotExist(err) {
+		return errs.NotFound("no previous binary to roll back to (%s)", prev)
+	}
+
+	current := exe + ".rollback"
+	os.Remove(current)
+	if err := link(exe, current); err != nil {
+		return fmt.Errorf("failed to keep the current binary: %v", err)
+	}
+	if err := os.Rename(prev, exe); err != nil {
+		os.Remove(current)
+		return fmt.Errorf("failed to restore the previous binary: %v", err)
+	}
+	if err := os.Rename(current, prev); err != nil {
+		return fmt.Errorf("failed to keep the current binary: %v", err)
+	}
+
+	fmt.Println("Restored the previous binary, run `support self-update --rollback` again to undo")
+	return nil
+}
+
+// link makes dst a hard link to src, or a copy if the file system does not
+// support links.
+func link(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
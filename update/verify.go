@@ -0,0 +1,87 @@
+package update
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+)
+
+// PublicKey is the base64 encoded ed25519 key releases are signed with,
+// set at build time with
+// -ldflags "-X go.codycody31.dev/support/update.PublicKey=<key>". The
+// update.public_key setting replaces it.
+var PublicKey = ""
+
+// Names of the checksum file of a release and of its signature.
+const (
+	checksumsName = "SHA256SUMS"
+	signatureName = "SHA256SUMS.sig"
+)
+
+func publicKey() (ed25519.PublicKey, error) {
+	encoded := PublicKey
+	if key := config.GetConfig().Update.PublicKey; key != "" {
+		encoded = key
+	}
+	if encoded == "" {
+		return nil, errs.Config("no key to verify releases with, set update.public_key")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errs.Config("invalid release public key, expected %d base64 encoded bytes", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// verifySignature checks that signature, raw or base64 encoded, is the
+// signature of checksums.
+func verifySignature(checksums, signature []byte) error {
+	key, err := publicKey()
+	if err != nil {
+		return err
+	}
+
+	if len(signature) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature)))
+		if err != nil {
+			return fmt.Errorf("invalid signature of %s: %v", checksumsName, err)
+		}
+		signature = decoded
+	}
+
+	if !ed25519.Verify(key, checksums, signature) {
+		return fmt.Errorf("invalid signature of %s, the release may have been tampered with", checksumsName)
+	}
+	return nil
+}
+
+// checksum returns the SHA-256 of name listed in checksums, in the format
+// of sha256sum.
+func checksum(checksums []byte, name string) ([]byte, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		// sha256sum marks files read in binary mode with *
+		if strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+
+		sum, err := hex.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid checksum of %s: %v", name, err)
+		}
+		return sum, nil
+	}
+	return nil, errs.NotFound("%s is not part of the release", name)
+}
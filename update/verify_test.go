@@ -0,0 +1,143 @@
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// release writes a signed release of binary to dir and returns its source.
+func release(t *testing.T, dir string, key ed25519.PrivateKey, binary []byte) source {
+	t.Helper()
+	version := filepath.Join(dir, "v1.0.0")
+	if err := os.MkdirAll(version, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(binary)
+	checksums := []byte(hex.EncodeToString(sum[:]) + "  " + assetName() + "\n")
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, checksums))
+	files := map[string][]byte{
+		assetName():   binary,
+		checksumsName: checksums,
+		signatureName: []byte(signature),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(version, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return newSource(dir)
+}
+
+func useKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := PublicKey
+	PublicKey = base64.StdEncoding.EncodeToString(public)
+	t.Cleanup(func() { PublicKey = previous })
+	return private
+}
+
+func TestDownload(t *testing.T) {
+	key := useKey(t)
+	dir := t.TempDir()
+	binary := []byte("#!/bin/sh\necho support\n")
+	src := release(t, dir, key, binary)
+
+	path := filepath.Join(t.TempDir(), "support.new")
+	if err := download(context.Background(), src, "v1.0.0", path); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(binary) {
+		t.Errorf("downloaded %q, expected %q", data, binary)
+	}
+}
+
+func TestDownloadTamperedChecksums(t *testing.T) {
+	key := useKey(t)
+	dir := t.TempDir()
+	src := release(t, dir, key, []byte("genuine"))
+
+	// Checksums listing another binary, signature left as is
+	evil := sha256.Sum256([]byte("evil"))
+	checksums := []byte(hex.EncodeToString(evil[:]) + "  " + assetName() + "\n")
+	if err := os.WriteFile(filepath.Join(dir, "v1.0.0", checksumsName), checksums, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "v1.0.0", assetName()), []byte("evil"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := download(context.Background(), src, "v1.0.0", filepath.Join(t.TempDir(), "support.new")); err == nil {
+		t.Error("download of tampered checksums succeeded")
+	}
+}
+
+func TestDownloadChecksumMismatch(t *testing.T) {
+	key := useKey(t)
+	dir := t.TempDir()
+	src := release(t, dir, key, []byte("genuine"))
+
+	if err := os.WriteFile(filepath.Join(dir, "v1.0.0", assetName()), []byte("corrupted"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := download(context.Background(), src, "v1.0.0", filepath.Join(t.TempDir(), "support.new")); err == nil {
+		t.Error("download of a binary not matching its checksum succeeded")
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	key := useKey(t)
+	checksums := []byte("0123  support_linux_amd64\n")
+	signature := ed25519.Sign(key, checksums)
+
+	if err := verifySignature(checksums, signature); err != nil {
+		t.Errorf("raw signature rejected: %v", err)
+	}
+	if err := verifySignature(checksums, []byte(base64.StdEncoding.EncodeToString(signature)+"\n")); err != nil {
+		t.Errorf("base64 signature rejected: %v", err)
+	}
+
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	if err := verifySignature(checksums, ed25519.Sign(other, checksums)); err == nil {
+		t.Error("signature of another key accepted")
+	}
+	if err := verifySignature(checksums, []byte("not a signature")); err == nil {
+		t.Error("invalid signature accepted")
+	}
+
+	PublicKey = ""
+	if err := verifySignature(checksums, signature); err == nil {
+		t.Error("signature verified without a public key")
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	checksums := []byte("00ff  support_linux_amd64\nabcd *support_darwin_arm64\n")
+
+	sum, err := checksum(checksums, "support_darwin_arm64")
+	if err != nil || hex.EncodeToString(sum) != "abcd" {
+		t.Errorf("checksum of support_darwin_arm64 is %x, %v", sum, err)
+	}
+	if _, err := checksum(checksums, "support_windows_amd64"); err == nil {
+		t.Error("checksum of a missing binary succeeded")
+	}
+	if _, err := checksum([]byte("zz  support_linux_amd64\n"), "support_linux_amd64"); err == nil {
+		t.Error("invalid checksum accepted")
+	}
+}
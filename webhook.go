@@ -0,0 +1,57 @@
+package main
+
+import (
+	"go.codycody31.dev/support/webhook"
+
+	"github.com/urfave/cli/v2"
+)
+
+var WebhookCommand = &cli.Command{
+	Name:  "webhook",
+	Usage: "Run routines and commands from incoming webhooks",
+	Description: `Hooks are read from the webhooks section of config.yaml:
+
+     webhooks:
+       listen: 127.0.0.1:9090
+       concurrency: 2
+       hooks:
+         - path: /deploy
+           secret: s3cret          # verify X-Hub-Signature-256
+           routine: deploy
+           vars:
+             branch: $.ref
+         - path: /notify
+           secret: s3cret
+           signature: gitea        # verify X-Gitea-Signature
+           command: ntfy send
+           flags:
+             topic: builds
+             message: $.head_commit.message
+
+   Values starting with $ are JSONPath expressions into the payload.`,
+	Subcommands: []*cli.Command{
+		{
+			Name:   "serve",
+			Usage:  "Receive webhooks in the foreground",
+			Action: webhook.ServeWebhooks,
+		},
+		{
+			Name:      "history",
+			Usage:     "List past deliveries, or show one",
+			ArgsUsage: "[id]",
+			Action:    webhook.ShowHistory,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "path",
+					Usage: "Only deliveries to this path",
+				},
+				&cli.IntFlag{
+					Name:    "limit",
+					Aliases: []string{"n"},
+					Usage:   "Number of deliveries to show",
+					Value:   20,
+				},
+			},
+		},
+	},
+}
@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"go.codycody31.dev/support/errs"
+)
+
+func ServeWebhooks(c *cli.Context) error {
+	return Serve(c.Context)
+}
+
+func ShowHistory(c *cli.Context) error {
+	executions, err := readHistory()
+	if err != nil {
+		return fmt.Errorf("failed to read webhook history: %v", err)
+	}
+
+	if id := c.Args().First(); id != "" {
+		for _, execution := range executions {
+			if execution.ID == id {
+				out, err := json.MarshalIndent(execution, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(out))
+				return nil
+			}
+		}
+		return errs.NotFound("webhook execution %s not found", id)
+	}
+
+	path := c.String("path")
+	var matches []*Execution
+	for _, execution := range executions {
+		if path == "" || execution.Path == path {
+			matches = append(matches, execution)
+		}
+	}
+	if limit := c.Int("limit"); limit > 0 && len(matches) > limit {
+		matches = matches[len(matches)-limit:]
+	}
+
+	if len(matches) == 0 {
+		fmt.Println("No webhook executions found")
+		return nil
+	}
+
+	for _, execution := range matches {
+		fmt.Printf("%s  %s  %-20s  %-8s  exit=%d  %dms  %s\n",
+			execution.ID,
+			execution.Time.Local().Format(time.RFC3339),
+			execution.Path,
+			execution.Status,
+			execution.ExitCode,
+			execution.DurationMS,
+			strings.Join(execution.Args, " "),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package webhook
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.codycody31.dev/support/config"
+)
+
+// Status of an execution.
+const (
+	StatusSuccess  = "success"
+	StatusFailure  = "failure"
+	StatusRejected = "rejected"
+)
+
+// maxOutput is the number of trailing bytes of output kept in the history.
+const maxOutput = 4096
+
+// Execution is a history record of a delivery.
+type Execution struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	Path       string    `json:"path"`
+	Delivery   string    `json:"delivery,omitempty"`
+	Event      string    `json:"event,omitempty"`
+	Args       []string  `json:"args,omitempty"`
+	Status     string    `json:"status"`
+	ExitCode   int       `json:"exit_code"`
+	DurationMS int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+	Output     string    `json:"output,omitempty"`
+}
+
+var historyMu sync.Mutex
+
+func historyFilePath() string {
+	return filepath.Join(config.SupportDir(), "webhook", "history.log")
+}
+
+func appendHistory(execution *Execution) error {
+	line, err := json.Marshal(execution)
+	if err != nil {
+		return err
+	}
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	path := historyFilePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// readHistory returns the executions, oldest first.
+func readHistory() ([]*Execution, error) {
+	file, err := os.Open(historyFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var executions []*Execution
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		execution := &Execution{}
+		if err := json.Unmarshal(scanner.Bytes(), execution); err != nil {
+			continue
+		}
+		executions = append(executions, execution)
+	}
+	return executions, scanner.Err()
+}
@@ -0,0 +1,85 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// extract returns the value at path in payload, a decoded JSON document.
+// path supports the subset of JSONPath used to pick a single value: $,
+// .name, ['name'] and [index], e.g. $.commits[0].author.name.
+func extract(payload interface{}, path string) (string, error) {
+	if !strings.HasPrefix(path, "$") {
+		return "", fmt.Errorf("invalid JSONPath %q: must start with $", path)
+	}
+
+	value := payload
+	rest := path[1:]
+	for rest != "" {
+		var key string
+		index := -1
+
+		switch {
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key = rest[1 : end+1]
+			rest = rest[end+1:]
+		case strings.HasPrefix(rest, "['") || strings.HasPrefix(rest, `["`):
+			end := strings.Index(rest[2:], string(rest[1])+"]")
+			if end < 0 {
+				return "", fmt.Errorf("invalid JSONPath %q: unterminated key", path)
+			}
+			key = rest[2 : end+2]
+			rest = rest[end+4:]
+		case rest[0] == '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return "", fmt.Errorf("invalid JSONPath %q: unterminated index", path)
+			}
+			n, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return "", fmt.Errorf("invalid JSONPath %q: invalid index %q", path, rest[1:end])
+			}
+			index = n
+			rest = rest[end+1:]
+		default:
+			return "", fmt.Errorf("invalid JSONPath %q", path)
+		}
+
+		if index >= 0 {
+			list, ok := value.([]interface{})
+			if !ok || index >= len(list) {
+				return "", fmt.Errorf("%s: no element %d", path, index)
+			}
+			value = list[index]
+			continue
+		}
+
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("%s: no field %q", path, key)
+		}
+		if value, ok = object[key]; !ok {
+			return "", fmt.Errorf("%s: no field %q", path, key)
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case nil:
+		return "", nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		encoded, err := json.Marshal(v)
+		return string(encoded), err
+	}
+}
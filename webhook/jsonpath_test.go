@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	var payload interface{}
+	err := json.Unmarshal([]byte(`{
+		"ref": "refs/heads/main",
+		"repository": {"name": "support", "full name": "codycody31/support", "private": false},
+		"commits": [
+			{"id": "a1", "author": {"name": "Alice"}, "added": ["README.md"]},
+			{"id": "b2", "author": {"name": "Bob"}, "added": []}
+		],
+		"matrix": [[1, 2], [3, 4]],
+		"number": 42,
+		"ratio": 0.5,
+		"draft": true,
+		"milestone": null,
+		"labels": {"a.b": "dotted"}
+	}`), &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"$.ref", "refs/heads/main"},
+		{"$.repository.name", "support"},
+		{"$['repository']['full name']", "codycody31/support"},
+		{`$["repository"]["name"]`, "support"},
+		{"$.repository.private", "false"},
+		{"$.commits[0].id", "a1"},
+		{"$.commits[1].author.name", "Bob"},
+		{"$.commits[0].added[0]", "README.md"},
+		{"$.commits[1].added", "[]"},
+		{"$.matrix[1][0]", "3"},
+		{"$.number", "42"},
+		{"$.ratio", "0.5"},
+		{"$.draft", "true"},
+		{"$.milestone", ""},
+		{"$.labels['a.b']", "dotted"},
+		{"$.repository", `{"full name":"codycody31/support","name":"support","private":false}`},
+	}
+	for _, test := range tests {
+		value, err := extract(payload, test.path)
+		if err != nil {
+			t.Errorf("extract(%q) failed: %v", test.path, err)
+			continue
+		}
+		if value != test.expected {
+			t.Errorf("extract(%q) = %q, expected %q", test.path, value, test.expected)
+		}
+	}
+
+	invalid := []string{
+		"ref",
+		"$.missing",
+		"$.repository.owner",
+		"$.ref.name",
+		"$.commits[2]",
+		"$.commits[-1]",
+		"$.commits[x]",
+		"$.commits[0",
+		"$['repository'",
+		"$.repository[0]",
+		"$.commits.id",
+		"$~",
+	}
+	for _, path := range invalid {
+		if value, err := extract(payload, path); err == nil {
+			t.Errorf("extract(%q) = %q, expected an error", path, value)
+		}
+	}
+
+	if _, err := extract(nil, "$.ref"); err == nil {
+		t.Error("extract of an empty payload succeeded")
+	}
+	if value, err := extract(nil, "$"); err != nil || value != "" {
+		t.Errorf("extract(nil, $) = %q, %v", value, err)
+	}
+}
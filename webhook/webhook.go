@@ -0,0 +1,334 @@
+// Package webhook receives webhooks from CI systems and CapRover and runs
+// the routines or commands mapped to their URL paths.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.codycody31.dev/support/config"
+	"go.codycody31.dev/support/errs"
+	"go.codycody31.dev/support/host"
+	"go.codycody31.dev/support/runner"
+	"go.codycody31.dev/support/shlex"
+)
+
+// maxPayload is the largest delivery accepted.
+const maxPayload = 5 << 20
+
+// Signature schemes.
+const (
+	SignatureGitHub = "github"
+	SignatureGitea  = "gitea"
+)
+
+type receiver struct {
+	hooks map[string]config.Webhook
+	queue chan *Execution
+	wg    sync.WaitGroup
+}
+
+// Serve receives webhooks until SIGINT or SIGTERM, then waits for the
+// queued executions to finish. A second signal interrupts them, and the
+// executions that did not start are recorded as failed.
+func Serve(ctx context.Context) error {
+	hooksConfig := config.GetConfig().Webhooks
+
+	r := &receiver{hooks: make(map[string]config.Webhook)}
+	for _, hook := range hooksConfig.Hooks {
+		if err := validate(hook); err != nil {
+			return errs.Config("invalid webhook %s: %v", hook.Path, err)
+		}
+		r.hooks[hook.Path] = hook
+	}
+	if len(r.hooks) == 0 {
+		return errs.Config("no webhooks configured in the webhooks section of config.yaml")
+	}
+
+	listen := hooksConfig.Listen
+	if listen == "" {
+		listen = "127.0.0.1:9090"
+	}
+	concurrency := hooksConfig.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	queueSize := hooksConfig.QueueSize
+	if queueSize <= 0 {
+		queueSize = 100
+	}
+
+	// Executions outlive ctx, so that the queue drains after a signal
+	runCtx, cancelRuns := context.WithCancel(context.Background())
+	defer cancelRuns()
+
+	r.queue = make(chan *Execution, queueSize)
+	for i := 0; i < concurrency; i++ {
+		r.wg.Add(1)
+		go r.worker(runCtx)
+	}
+
+	server := &http.Server{Addr: listen, Handler: r}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("receiving webhooks on %s (%d hook(s), concurrency %d)", listen, len(r.hooks), concurrency)
+	err := server.ListenAndServe()
+	if err != http.ErrServerClosed {
+		return errs.Config("failed to listen on %s: %v", listen, err)
+	}
+
+	close(r.queue)
+	if pending := len(r.queue); pending > 0 {
+		log.Printf("waiting for %d queued execution(s), interrupt again to stop them", pending)
+	}
+
+	// The next signal stops the executions instead of exiting
+	drainCtx, release := host.Interruptible(context.Background())
+	defer release()
+	go func() {
+		<-drainCtx.Done()
+		cancelRuns()
+	}()
+
+	r.wg.Wait()
+	return nil
+}
+
+func validate(hook config.Webhook) error {
+	if !strings.HasPrefix(hook.Path, "/") {
+		return fmt.Errorf("path must start with /")
+	}
+	if (hook.Routine == "") == (hook.Command == "") {
+		return fmt.Errorf("exactly one of routine and command must be set")
+	}
+	if hook.Routine != "" && len(hook.Flags) > 0 {
+		return fmt.Errorf("flags only apply to commands, use vars for routines")
+	}
+	switch hook.Signature {
+	case "", SignatureGitHub, SignatureGitea:
+	default:
+		return fmt.Errorf("invalid signature %q, expected github or gitea", hook.Signature)
+	}
+	return nil
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	hook, exists := r.hooks[req.URL.Path]
+	if !exists {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	execution := &Execution{
+		ID:       fmt.Sprintf("%x", time.Now().UnixNano()),
+		Time:     time.Now().UTC(),
+		Path:     hook.Path,
+		Delivery: firstHeader(req, "X-GitHub-Delivery", "X-Gitea-Delivery"),
+		Event:    firstHeader(req, "X-GitHub-Event", "X-Gitea-Event"),
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxPayload))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	if err := verify(hook, req.Header, body); err != nil {
+		r.reject(w, execution, http.StatusUnauthorized, err)
+		return
+	}
+
+	execution.Args, err = commandLine(hook, body)
+	if err != nil {
+		r.reject(w, execution, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	select {
+	case r.queue <- execution:
+		log.Printf("delivery %s to %s queued as %s", execution.Delivery, hook.Path, execution.ID)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"id": execution.ID})
+	default:
+		r.reject(w, execution, http.StatusServiceUnavailable, fmt.Errorf("queue full"))
+	}
+}
+
+func (r *receiver) reject(w http.ResponseWriter, execution *Execution, status int, err error) {
+	log.Printf("delivery %s to %s rejected: %v", execution.Delivery, execution.Path, err)
+
+	execution.Status = StatusRejected
+	execution.Error = err.Error()
+	if err := appendHistory(execution); err != nil {
+		log.Printf("failed to write webhook history: %v", err)
+	}
+
+	http.Error(w, err.Error(), status)
+}
+
+func (r *receiver) worker(ctx context.Context) {
+	defer r.wg.Done()
+
+	for execution := range r.queue {
+		if err := ctx.Err(); err != nil {
+			execution.Status = StatusFailure
+			execution.ExitCode = errs.ExitCode(err)
+			execution.Error = "not run, the receiver was stopped"
+			log.Printf("execution %s (%s) dropped", execution.ID, strings.Join(execution.Args, " "))
+			if err := appendHistory(execution); err != nil {
+				log.Printf("failed to write webhook history: %v", err)
+			}
+			continue
+		}
+
+		var output bytes.Buffer
+		result, err := runner.Run(ctx, execution.Args, nil, &output, &output)
+
+		execution.Status = StatusSuccess
+		switch {
+		case err != nil:
+			execution.Status = StatusFailure
+			execution.ExitCode = errs.ExitCode(err)
+			execution.Error = err.Error()
+		case result.ExitCode != 0:
+			execution.Status = StatusFailure
+			execution.ExitCode = result.ExitCode
+		}
+		if result != nil {
+			execution.DurationMS = result.Duration.Milliseconds()
+		}
+
+		out := output.String()
+		if len(out) > maxOutput {
+			out = out[len(out)-maxOutput:]
+		}
+		execution.Output = out
+
+		log.Printf("execution %s (%s) finished: %s, exit code %d", execution.ID, strings.Join(execution.Args, " "), execution.Status, execution.ExitCode)
+		if err := appendHistory(execution); err != nil {
+			log.Printf("failed to write webhook history: %v", err)
+		}
+	}
+}
+
+// verify checks the HMAC-SHA256 signature of the body.
+func verify(hook config.Webhook, header http.Header, body []byte) error {
+	if hook.Secret == "" {
+		return nil
+	}
+
+	var signature string
+	switch hook.Signature {
+	case SignatureGitea:
+		signature = header.Get("X-Gitea-Signature")
+	default:
+		signature = strings.TrimPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+	}
+	if signature == "" {
+		return fmt.Errorf("missing signature")
+	}
+
+	given, err := hex.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature")
+	}
+
+	mac := hmac.New(sha256.New, []byte(hook.Secret))
+	mac.Write(body)
+	if !hmac.Equal(given, mac.Sum(nil)) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// commandLine returns the arguments of support run for a delivery.
+func commandLine(hook config.Webhook, body []byte) ([]string, error) {
+	var payload interface{}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("invalid JSON payload: %v", err)
+		}
+	}
+
+	if hook.Routine != "" {
+		args := []string{"routine", "run"}
+		vars, err := resolve(hook.Vars, payload)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range sortedKeys(vars) {
+			args = append(args, "--var", name+"="+vars[name])
+		}
+		return append(args, hook.Routine), nil
+	}
+
+	args, err := shlex.Split(hook.Command)
+	if err != nil {
+		return nil, err
+	}
+	flags, err := resolve(hook.Flags, payload)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range sortedKeys(flags) {
+		args = append(args, "--"+name+"="+flags[name])
+	}
+	return args, nil
+}
+
+// resolve replaces the JSONPath expressions of values with the values they
+// select in payload.
+func resolve(values map[string]string, payload interface{}) (map[string]string, error) {
+	resolved := make(map[string]string, len(values))
+	for name, value := range values {
+		if strings.HasPrefix(value, "$") {
+			extracted, err := extract(payload, value)
+			if err != nil {
+				return nil, err
+			}
+			value = extracted
+		}
+		resolved[name] = value
+	}
+	return resolved, nil
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func firstHeader(req *http.Request, names ...string) string {
+	for _, name := range names {
+		if value := req.Header.Get(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
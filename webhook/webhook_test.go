@@ -0,0 +1,141 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"go.codycody31.dev/support/config"
+)
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"ref":"refs/heads/main"}`)
+	good := sign("s3cret", body)
+	github := config.Webhook{Path: "/deploy", Secret: "s3cret", Routine: "deploy"}
+	gitea := config.Webhook{Path: "/deploy", Secret: "s3cret", Signature: SignatureGitea, Routine: "deploy"}
+
+	tests := []struct {
+		name   string
+		hook   config.Webhook
+		header map[string]string
+		body   []byte
+		valid  bool
+	}{
+		{
+			name:   "github",
+			hook:   github,
+			header: map[string]string{"X-Hub-Signature-256": "sha256=" + good},
+			valid:  true,
+		},
+		{
+			name:   "gitea",
+			hook:   gitea,
+			header: map[string]string{"X-Gitea-Signature": good},
+			valid:  true,
+		},
+		{
+			name:  "no secret",
+			hook:  config.Webhook{Path: "/deploy", Routine: "deploy"},
+			valid: true,
+		},
+		{
+			name:   "github missing",
+			hook:   github,
+			header: map[string]string{"X-Gitea-Signature": good},
+		},
+		{
+			name:   "gitea missing",
+			hook:   gitea,
+			header: map[string]string{"X-Hub-Signature-256": "sha256=" + good},
+		},
+		{
+			name:   "wrong secret",
+			hook:   github,
+			header: map[string]string{"X-Hub-Signature-256": "sha256=" + sign("other", body)},
+		},
+		{
+			name:   "tampered body",
+			hook:   github,
+			header: map[string]string{"X-Hub-Signature-256": "sha256=" + good},
+			body:   []byte(`{"ref":"refs/heads/evil"}`),
+		},
+		{
+			name:   "not hex",
+			hook:   github,
+			header: map[string]string{"X-Hub-Signature-256": "sha256=not-a-signature"},
+		},
+		{
+			name:   "truncated",
+			hook:   gitea,
+			header: map[string]string{"X-Gitea-Signature": good[:32]},
+		},
+		{
+			name:   "sha1 prefix",
+			hook:   github,
+			header: map[string]string{"X-Hub-Signature-256": "sha1=" + good},
+		},
+	}
+
+	for _, test := range tests {
+		header := http.Header{}
+		for name, value := range test.header {
+			header.Set(name, value)
+		}
+		payload := body
+		if test.body != nil {
+			payload = test.body
+		}
+
+		err := verify(test.hook, header, payload)
+		if test.valid && err != nil {
+			t.Errorf("%s: verify failed: %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: verify succeeded, expected an error", test.name)
+		}
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	body := []byte(`{"ref":"refs/heads/main","head_commit":{"message":"Fix it"}}`)
+
+	args, err := commandLine(config.Webhook{
+		Command: "ntfy send --tags ci",
+		Flags:   map[string]string{"topic": "builds", "message": "$.head_commit.message"},
+	}, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"ntfy", "send", "--tags", "ci", "--message=Fix it", "--topic=builds"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("command line is %q, expected %q", args, expected)
+	}
+
+	args, err = commandLine(config.Webhook{
+		Routine: "deploy",
+		Vars:    map[string]string{"branch": "$.ref"},
+	}, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"routine", "run", "--var", "branch=refs/heads/main", "deploy"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("command line is %q, expected %q", args, expected)
+	}
+
+	if _, err := commandLine(config.Webhook{Routine: "deploy", Vars: map[string]string{"branch": "$.missing"}}, body); err == nil {
+		t.Error("a missing path succeeded")
+	}
+	if _, err := commandLine(config.Webhook{Routine: "deploy"}, []byte("not json")); err == nil {
+		t.Error("an invalid payload succeeded")
+	}
+}